@@ -17,6 +17,7 @@
 import (
 	"net/http/httptest"
 	"strings"
+	"testing"
 
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
@@ -29,16 +30,39 @@
 )
 
 // Data is a helper struct to define the parameters of a request for a test case.
+//
+// The Seeds are loaded into the postgres database right before the handler runs, as described by
+// IntegrationTest.Seed. Combined with IsolateCases, every case starts from the init script and its own seeds. Only
+// AssertAll loads them, since Assert is called after the handler ran. Custom loops call IntegrationTest.Seed instead,
+// and Assert fails a case that has Seeds.
+//
+// ExpectDBState names a fixture under 'fixtures/db', in YAML or JSON, with the rows that the tables in it are expected
+// to hold after the handler ran. Only the columns in the fixture are compared, so a subset of the columns is enough,
+// and the IgnoreDBColumns, like 'created_at' for all tables or 'visits.id' for a single one, are left out. The rows
+// are compared in the order of the primary key of the table.
+//
+// AssertAll records the statements that the handler runs through gorm. ExpectMaxQueries limits their number, which
+// catches N+1 queries, ExpectQueries and ForbidQueries list texts that a statement must or must not contain, and
+// ExpectQueryLog names a query log under 'fixtures/queries' that the statements must match. The statements are
+// recorded with placeholders instead of their values. When a case fails, its statements are logged. Only AssertAll
+// records the statements, since it calls the handler itself, so Assert fails a case that has query expectations.
 type Data struct {
 	Name               string
 	Method             string
 	Params             Params
 	Handler            func(ctx echo.Context) error
 	Mocks              []MockCall
+	Seeds              []string
 	ExpectResponse     string
 	ExpectErrResponse  bool
 	ExpectCode         int
 	ExpectResponseType string
+	ExpectDBState      string
+	IgnoreDBColumns    []string
+	ExpectMaxQueries   int
+	ExpectQueries      []string
+	ForbidQueries      []string
+	ExpectQueryLog     string
 }
 
 // HandlerResult holds the result of a handler, the error that possibly was returned and the response recorder.
@@ -72,9 +96,20 @@ type HandlerResult struct {
 //		  })
 //		}
 func Assert(it *IntegrationTest, t *Data, res *HandlerResult) {
-	it.T.Log(it.T.Name(), "/", t.Name)
+	it.tb.Log(it.tb.Name(), "/", t.Name)
+
+	if len(t.Seeds) > 0 {
+		it.tb.Errorf("the Seeds of '%s' are only loaded by AssertAll, call it.Seed before the handler runs instead", t.Name)
+	}
+
+	if expectsQueries(t) {
+		it.tb.Errorf(
+			"the statements of '%s' are only recorded by AssertAll, its query expectations cannot be asserted", t.Name,
+		)
+	}
 
-	assertHandlerResult(it, t, res)
+	assertHandlerResult(it, it.tb, t, res)
+	assertDBState(it, it.tb, t)
 }
 
 // LoadMocks loads the mocks for a given test case.
@@ -86,58 +121,122 @@ func LoadMocks(it *IntegrationTest, t *Data) {
 	}
 }
 
+// loadMocks loads the mocks for a given test case like LoadMocks, reporting the failures to the given test.
+func loadMocks(it *IntegrationTest, tb testing.TB, t *Data) {
+	if it.Mock == nil {
+		return
+	}
+
+	for i := range t.Mocks {
+		err := it.Mock.mockRequest(t.Mocks[i].Config)
+		require.NoError(tb, err, "could not load mock")
+	}
+}
+
 // AssertAll runs the given tests and asserts their result. The handler function is called inside the assertion method.
+// Every test runs as a subtest named after it, so a failing test does not stop the others and a single test can be
+// selected with 'go test -run'.
 func AssertAll(it *IntegrationTest, tt []Data) {
 	for _, t := range tt {
-		it.T.Log(it.T.Name(), "/", t.Name)
+		runCase(it, t.Name, func(tb testing.TB) {
+			if it.resetDB != nil {
+				it.resetDBFor(tb)
+			}
+
+			if len(t.Seeds) > 0 {
+				require.NoError(tb, it.seed(it.ctx, t.Seeds...), "could not seed the database")
+			}
 
-		LoadMocks(it, &t)
+			loadMocks(it, tb, &t)
 
-		ctx, response := Request(it, t.Method, t.Params)
-		err := t.Handler(ctx)
-		if err != nil {
-			it.T.Log(err.Error())
-		}
+			ctx, response := request(it, tb, t.Method, t.Params)
 
-		assertHandlerResult(it, &t, &HandlerResult{
-			Err:      err,
-			Response: response,
+			it.queries.start()
+			err := t.Handler(ctx)
+			statements, recorded := it.queries.stop()
+			if err != nil {
+				tb.Log(err.Error())
+			}
+
+			defer func() {
+				if tb.Failed() && len(statements) > 0 {
+					tb.Logf("statements run by the handler:\n%s", formatQueries(statements))
+				}
+			}()
+
+			assertHandlerResult(it, tb, &t, &HandlerResult{
+				Err:      err,
+				Response: response,
+			})
+			assertQueries(it, tb, &t, statements, recorded)
+			assertDBState(it, tb, &t)
 		})
 	}
 }
 
-// assertHandlerResult asserts the result of a handler.
-func assertHandlerResult(it *IntegrationTest, t *Data, res *HandlerResult) {
+// runCase runs a test case as a subtest. Subtests are only available for a *testing.T, for any other testing.TB,
+// like a *testing.B, the case runs directly on it.
+func runCase(it *IntegrationTest, name string, fn func(tb testing.TB)) {
+	t, ok := it.tb.(*testing.T)
+	if !ok {
+		it.tb.Log(it.tb.Name(), "/", name)
+		fn(it.tb)
+		return
+	}
+
+	t.Run(name, func(st *testing.T) {
+		fn(st)
+	})
+}
+
+// assertHandlerResult asserts the result of a handler, reporting the failures to the given test.
+func assertHandlerResult(it *IntegrationTest, tb testing.TB, t *Data, res *HandlerResult) {
 	if t.ExpectErrResponse {
-		require.Error(it.T, res.Err)
+		require.Error(tb, res.Err)
 
 		echoErr, match := res.Err.(*echo.HTTPError)
-		require.True(it.T, match)
+		require.True(tb, match)
 
 		res.Response.Code = echoErr.Code
 	} else {
-		require.NoError(it.T, res.Err)
+		require.NoError(tb, res.Err)
 	}
 
-	require.Equal(it.T, t.ExpectCode, res.Response.Code)
+	require.Equal(tb, t.ExpectCode, res.Response.Code)
+
+	// In update mode, the actual response becomes the expected one.
+	if strings.TrimSpace(t.ExpectResponse) != "" && updateFixtures() {
+		err := it.Fixtures.WriteResponse(t.ExpectResponse, t.ExpectResponseType, res.Response.Body.Bytes())
+		require.NoError(tb, err, "could not update fixture")
+
+		tb.Logf("updated fixture '%s'", t.ExpectResponse)
+		return
+	}
 
 	if strings.TrimSpace(t.ExpectResponse) != "" {
 		if t.ExpectResponseType == Excel {
-			expectedRows := it.Fixtures.ReadExcelFile(t.ExpectResponse)
+			expectedRows, err := it.Fixtures.ReadExcelFileE(t.ExpectResponse)
+			require.NoError(tb, err)
 
 			responseRows, err := it.Fixtures.ExcelToMap(res.Response.Body.Bytes())
 			if err != nil {
-				it.T.Fatal(err.Error())
+				tb.Fatal(err.Error())
 			}
 
-			require.Equal(it.T, expectedRows, responseRows)
+			require.Equal(tb, expectedRows, responseRows)
 		} else if t.ExpectResponseType == CSV {
-			expectedRows := strings.TrimSpace(it.Fixtures.ReadCsvFile(t.ExpectResponse))
-			assert.Equal(it.T, expectedRows, strings.TrimSpace(res.Response.Body.String()), "csv data mismatch")
+			expectedRows, err := it.Fixtures.ReadCsvFileE(t.ExpectResponse)
+			require.NoError(tb, err)
+
+			expectedRows = strings.TrimSpace(expectedRows)
+			assert.Equal(tb, expectedRows, strings.TrimSpace(res.Response.Body.String()), "csv data mismatch")
 		} else {
-			t.ExpectResponse = it.Fixtures.ReadResponse(t.ExpectResponse)
+			expectResponse, err := it.Fixtures.ReadResponseE(t.ExpectResponse)
+			require.NoError(tb, err)
+
+			t.ExpectResponse = expectResponse
 
-			require.JSONEq(it.T,
+			require.JSONEq(tb,
 				t.ExpectResponse,
 				strings.TrimSpace(res.Response.Body.String()),
 			)
@@ -15,13 +15,20 @@
 package echoprobe
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
+	"sync"
 
-	"github.com/docker/docker/api/types/container"
-	"github.com/docker/docker/api/types/mount"
+	"cloud.google.com/go/bigquery"
+	storage "cloud.google.com/go/bigquery/storage/apiv1"
 	"github.com/testcontainers/testcontainers-go"
 	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 const (
@@ -31,44 +38,80 @@
 	bqProject   = "test"
 )
 
+var errNoBigQuery = errors.New("there is no BigQuery emulator, use IntegrationTestWithBigQuery")
+
 type BigqueryEmulatorContainer struct {
 	testcontainers.Container
 
 	BqHost     string
 	BqRestPort int
 	BqGrpcPort int
+
+	// Project is the project of the emulator, which the data files can add others to.
+	Project string
+
+	// logs holds the last lines of the logs of the container.
+	logs *containerLogs
 }
 
-func setupBigqueryEmulator(ctx context.Context, dataPath string) (*BigqueryEmulatorContainer, error) {
-	executionPath, err := testpath()
+// setupBigqueryEmulator sets up a BigQuery emulator test container, configured by the option. The data files are
+// read from the root of the fixtures, for example 'fixtures/bigquery/data.yaml', and merged into one. The emulator
+// cannot be reset to its data files, so it is not reused across test runs, not even in the reuse mode. The logs of the
+// container are passed to the log consumer.
+func setupBigqueryEmulator(
+	ctx context.Context, fixtures *Fixtures, o IntegrationTestWithBigQuery, logs *containerLogs,
+) (_ *BigqueryEmulatorContainer, err error) {
+	project := o.Project
+	if strings.TrimSpace(project) == "" {
+		project = bqProject
+	}
+
+	paths, err := o.dataPaths(fixtures)
 	if err != nil {
 		return nil, err
 	}
 
+	var data []byte
+	if len(paths) > 0 {
+		data, err = readBigQueryData(fixtures, paths)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	req := testcontainers.ContainerRequest{
-		Image: "ghcr.io/goccy/bigquery-emulator:latest",
-		HostConfigModifier: func(config *container.HostConfig) {
-			config.Mounts = append(config.Mounts, mount.Mount{
-				Type:   mount.TypeBind,
-				Source: fmt.Sprintf("%s/%s", executionPath, dataPath),
-				Target: bqMountPath,
-			})
-		},
-		Cmd: []string{
-			fmt.Sprintf("--project=%s", bqProject),
-			fmt.Sprintf("--data-from-yaml=%s", bqMountPath),
-		},
+		Image:        "ghcr.io/goccy/bigquery-emulator:latest",
+		Cmd:          []string{fmt.Sprintf("--project=%s", project)},
 		ExposedPorts: []string{bqHttpPort, bqGrpcPort},
 		WaitingFor: wait.ForAll(
 			wait.ForListeningPort(bqGrpcPort),
 			wait.ForListeningPort(bqHttpPort),
 		),
+		LogConsumerCfg: logs.config(),
+	}
+
+	if data != nil {
+		req.Files = []testcontainers.ContainerFile{
+			{
+				Reader:            bytes.NewReader(data),
+				ContainerFilePath: bqMountPath,
+				FileMode:          0644,
+			},
+		}
+		req.Cmd = append(req.Cmd, fmt.Sprintf("--data-from-yaml=%s", bqMountPath))
 	}
 
+	req.Cmd = append(req.Cmd, o.Flags...)
+
 	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
 		ContainerRequest: req,
 		Started:          true,
 	})
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, testcontainers.TerminateContainer(container))
+		}
+	}()
 	if err != nil {
 		return nil, err
 	}
@@ -93,5 +136,91 @@ func setupBigqueryEmulator(ctx context.Context, dataPath string) (*BigqueryEmula
 		BqHost:     hostIP,
 		BqRestPort: mappedHttpPort.Int(),
 		BqGrpcPort: mappedGrpcPort.Int(),
+		Project:    project,
+		logs:       logs,
 	}, nil
 }
+
+// bigqueryClients holds the clients of the BigQuery emulator of an integration test, which are created the first time
+// they are asked for.
+type bigqueryClients struct {
+	mu         sync.Mutex
+	client     *bigquery.Client
+	readClient *storage.BigQueryReadClient
+}
+
+// BigQueryClient returns a BigQuery client for the REST API of the emulator of the integration test, in the Project
+// of the emulator. The client is closed when the test is torn down.
+func (it *IntegrationTest) BigQueryClient(ctx context.Context) *bigquery.Client {
+	if it.BqContainer == nil {
+		it.fail(errNoBigQuery)
+		return nil
+	}
+
+	it.bq.mu.Lock()
+	defer it.bq.mu.Unlock()
+
+	if it.bq.client == nil {
+		client, err := bigquery.NewClient(
+			ctx, it.BqContainer.Project,
+			option.WithEndpoint(fmt.Sprintf("http://%s:%d", it.BqContainer.BqHost, it.BqContainer.BqRestPort)),
+			option.WithoutAuthentication(),
+		)
+		if err != nil {
+			it.fail(fmt.Errorf("bigquery connection error: %w", err))
+			return nil
+		}
+
+		it.bq.client = client
+	}
+
+	return it.bq.client
+}
+
+// BigQueryReadClient returns a client of the BigQuery Storage Read API, for the gRPC API of the emulator of the
+// integration test. The client is closed when the test is torn down.
+func (it *IntegrationTest) BigQueryReadClient(ctx context.Context) *storage.BigQueryReadClient {
+	if it.BqContainer == nil {
+		it.fail(errNoBigQuery)
+		return nil
+	}
+
+	it.bq.mu.Lock()
+	defer it.bq.mu.Unlock()
+
+	if it.bq.readClient == nil {
+		client, err := storage.NewBigQueryReadClient(
+			ctx,
+			option.WithEndpoint(fmt.Sprintf("%s:%d", it.BqContainer.BqHost, it.BqContainer.BqGrpcPort)),
+			option.WithoutAuthentication(),
+			option.WithGRPCDialOption(grpc.WithTransportCredentials(insecure.NewCredentials())),
+		)
+		if err != nil {
+			it.fail(fmt.Errorf("bigquery connection error: %w", err))
+			return nil
+		}
+
+		it.bq.readClient = client
+	}
+
+	return it.bq.readClient
+}
+
+// close closes the clients that were created.
+func (c *bigqueryClients) close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var errs []error
+	if c.readClient != nil {
+		errs = append(errs, c.readClient.Close())
+		c.readClient = nil
+	}
+
+	if c.client != nil {
+		errs = append(errs, c.client.Close())
+		c.client = nil
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,214 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// dataPaths returns the data files of the option: the DataPath, the DataPaths and the YAML files in the DataDir, in
+// that order. The paths are relative to the root of the fixtures.
+func (o IntegrationTestWithBigQuery) dataPaths(fixtures *Fixtures) ([]string, error) {
+	var paths []string
+	if strings.TrimSpace(o.DataPath) != "" {
+		paths = append(paths, o.DataPath)
+	}
+
+	paths = append(paths, o.DataPaths...)
+
+	if strings.TrimSpace(o.DataDir) == "" {
+		return paths, nil
+	}
+
+	dir := path.Clean(strings.TrimPrefix(o.DataDir, "/"))
+
+	root, name, _, err := fixtures.resolve(dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read data directory: %w", err)
+	}
+
+	entries, err := fs.ReadDir(root, name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read data directory: %w", err)
+	}
+
+	var names []string
+	for _, entry := range entries {
+		ext := path.Ext(entry.Name())
+		if !entry.IsDir() && (ext == ".yaml" || ext == ".yml") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		paths = append(paths, path.Join(dir, name))
+	}
+
+	return paths, nil
+}
+
+// readBigQueryData reads the data files and merges them into one. A single file is passed on as it is. Projects and
+// datasets that occur in more than one file are merged, but a table can only be defined once. The files are merged as
+// YAML nodes, so that the values come out exactly as they are written, like dates or numbers with leading zeros.
+func readBigQueryData(fixtures *Fixtures, paths []string) ([]byte, error) {
+	if len(paths) == 1 {
+		return fixtures.readFile(paths[0])
+	}
+
+	merged := &yaml.Node{Kind: yaml.MappingNode}
+	tables := make(map[string]string)
+
+	for _, p := range paths {
+		buf, err := fixtures.readFile(p)
+		if err != nil {
+			return nil, err
+		}
+
+		var doc yaml.Node
+		err = yaml.Unmarshal(buf, &doc)
+		if err != nil {
+			return nil, fmt.Errorf("invalid data file '%s': %w", p, err)
+		}
+
+		// An empty file has no content at all.
+		if len(doc.Content) == 0 {
+			continue
+		}
+
+		data := doc.Content[0]
+		if data.Kind != yaml.MappingNode {
+			return nil, fmt.Errorf("invalid data file '%s': the projects are not in a mapping", p)
+		}
+
+		mergeNodes(merged, data, "projects")
+
+		for _, project := range sequence(data, "projects") {
+			targetProject := mergeEntry(merged, "projects", project, "datasets")
+
+			for _, dataset := range sequence(project, "datasets") {
+				targetDataset := mergeEntry(targetProject, "datasets", dataset, "tables")
+
+				for _, table := range sequence(dataset, "tables") {
+					id := fmt.Sprintf("%s.%s.%s", scalar(project, "id"), scalar(dataset, "id"), scalar(table, "id"))
+					if other, ok := tables[id]; ok {
+						return nil, fmt.Errorf("table '%s' is defined in both '%s' and '%s'", id, other, p)
+					}
+					tables[id] = p
+
+					list := ensureSequence(targetDataset, "tables")
+					list.Content = append(list.Content, table)
+				}
+			}
+		}
+	}
+
+	return yaml.Marshal(merged)
+}
+
+// mergeEntry adds the entry of the list under the key, like a project, to the mapping, without the nested list, and
+// returns where the items of the nested list go. An entry with the same id is merged into.
+func mergeEntry(mapping *yaml.Node, key string, entry *yaml.Node, nested string) *yaml.Node {
+	list := ensureSequence(mapping, key)
+
+	for _, existing := range list.Content {
+		if scalar(existing, "id") == scalar(entry, "id") {
+			mergeNodes(existing, entry, "id", nested)
+			return existing
+		}
+	}
+
+	target := &yaml.Node{Kind: yaml.MappingNode}
+	mergeNodes(target, entry, nested)
+	list.Content = append(list.Content, target)
+
+	return target
+}
+
+// mergeNodes merges the keys of a mapping into another one, except the skipped ones. Lists are concatenated, any other
+// value is taken from the later file.
+func mergeNodes(into, from *yaml.Node, skip ...string) {
+	for i := 0; i+1 < len(from.Content); i += 2 {
+		key, value := from.Content[i], from.Content[i+1]
+		if slices.Contains(skip, key.Value) {
+			continue
+		}
+
+		existing := lookup(into, key.Value)
+		switch {
+		case existing == nil:
+			into.Content = append(into.Content, key, value)
+		case existing.Kind == yaml.SequenceNode && value.Kind == yaml.SequenceNode:
+			existing.Content = append(existing.Content, value.Content...)
+		default:
+			*existing = *value
+		}
+	}
+}
+
+// lookup returns the value of the key in the mapping, or nil when it has none.
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	if mapping.Kind != yaml.MappingNode {
+		return nil
+	}
+
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+
+	return nil
+}
+
+// scalar returns the value of the key in the mapping, or an empty string when it has none.
+func scalar(mapping *yaml.Node, key string) string {
+	if value := lookup(mapping, key); value != nil {
+		return value.Value
+	}
+
+	return ""
+}
+
+// sequence returns the items of the list under the key in the mapping.
+func sequence(mapping *yaml.Node, key string) []*yaml.Node {
+	if value := lookup(mapping, key); value != nil && value.Kind == yaml.SequenceNode {
+		return value.Content
+	}
+
+	return nil
+}
+
+// ensureSequence returns the list under the key in the mapping, adding an empty one when it has none.
+func ensureSequence(mapping *yaml.Node, key string) *yaml.Node {
+	if value := lookup(mapping, key); value != nil && value.Kind == yaml.SequenceNode {
+		return value
+	}
+
+	list := &yaml.Node{Kind: yaml.SequenceNode}
+	mapping.Content = append(mapping.Content,
+		&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+		list,
+	)
+
+	return list
+}
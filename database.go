@@ -0,0 +1,197 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"gorm.io/gorm"
+)
+
+var (
+	errNoPostgres = errors.New("there is no postgres database, use IntegrationTestWithPostgres")
+	errNoGorm     = errors.New("gorm is not connected, use IntegrationTestWithPostgres without SkipGorm")
+)
+
+// databases holds the connections to the postgres database of an integration test. The connection pool of gorm is
+// shared with SQLDB, the others are opened the first time they are asked for.
+type databases struct {
+	mu    sync.Mutex
+	sqlDB *sql.DB
+	pool  *pgxpool.Pool
+}
+
+// connect opens gorm on the postgres database of the integration test, unless the option skips it.
+func (it *IntegrationTest) connect(o IntegrationTestWithPostgres) error {
+	if o.SkipGorm {
+		return nil
+	}
+
+	config := o.Config
+	if config == nil {
+		config = &gorm.Config{}
+	}
+
+	db, err := openGorm(it.DSN(), config)
+	if err != nil {
+		return err
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	err = it.queries.install(db)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	it.Db = db
+	it.db.sqlDB = sqlDB
+
+	return nil
+}
+
+// DSN returns the URL of the postgres database of the integration test, for any other client to connect with.
+func (it *IntegrationTest) DSN() string {
+	if it.Container == nil {
+		it.fail(errNoPostgres)
+		return ""
+	}
+
+	return it.Container.dsn(it.Container.DBName)
+}
+
+// Gorm returns the gorm connection to the postgres database of the integration test.
+func (it *IntegrationTest) Gorm() *gorm.DB {
+	db, ok := it.Db.(*gorm.DB)
+	if !ok {
+		it.fail(errNoGorm)
+		return nil
+	}
+
+	return db
+}
+
+// SQLDB returns a database/sql connection pool to the postgres database of the integration test, which can also be
+// wrapped by sqlx. When gorm is connected, it is the pool of gorm.
+func (it *IntegrationTest) SQLDB() *sql.DB {
+	db, err := it.sqlDB()
+	if err != nil {
+		it.fail(err)
+		return nil
+	}
+
+	return db
+}
+
+// sqlDB returns the database/sql connection pool like SQLDB, but returns the errors instead of failing the test.
+func (it *IntegrationTest) sqlDB() (*sql.DB, error) {
+	if it.Container == nil {
+		return nil, errNoPostgres
+	}
+
+	it.db.mu.Lock()
+	defer it.db.mu.Unlock()
+
+	if it.db.sqlDB == nil {
+		db, err := sql.Open("postgres", it.Container.dsn(it.Container.DBName))
+		if err != nil {
+			return nil, fmt.Errorf("database connection error: %w", err)
+		}
+
+		it.db.sqlDB = db
+	}
+
+	return it.db.sqlDB, nil
+}
+
+// PgxPool returns a pgx connection pool to the postgres database of the integration test.
+func (it *IntegrationTest) PgxPool() *pgxpool.Pool {
+	dsn := it.DSN()
+	if dsn == "" {
+		return nil
+	}
+
+	it.db.mu.Lock()
+	defer it.db.mu.Unlock()
+
+	if it.db.pool == nil {
+		pool, err := pgxpool.New(it.ctx, dsn)
+		if err != nil {
+			it.fail(fmt.Errorf("database connection error: %w", err))
+			return nil
+		}
+
+		it.db.pool = pool
+	}
+
+	return it.db.pool
+}
+
+// connections returns the connection pools that are open.
+func (d *databases) connections() (*sql.DB, *pgxpool.Pool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.sqlDB, d.pool
+}
+
+// closeIdleConns closes the idle connections of the pool, without changing its settings. They are taken out of the
+// pool together, so that none of them is handed out twice, and discarded like broken connections.
+func closeIdleConns(ctx context.Context, db *sql.DB) error {
+	conns := make([]*sql.Conn, 0, db.Stats().Idle)
+	defer func() {
+		for _, conn := range conns {
+			_ = conn.Raw(func(any) error {
+				return driver.ErrBadConn
+			})
+		}
+	}()
+
+	for range cap(conns) {
+		conn, err := db.Conn(ctx)
+		if err != nil {
+			return err
+		}
+
+		conns = append(conns, conn)
+	}
+
+	return nil
+}
+
+// close closes the connection pools that are open, including the one of gorm.
+func (d *databases) close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.pool != nil {
+		d.pool.Close()
+		d.pool = nil
+	}
+
+	if d.sqlDB != nil {
+		_ = d.sqlDB.Close()
+		d.sqlDB = nil
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/require"
+)
+
+// DBState holds the rows of database tables, by the name of the table.
+type DBState map[string][]map[string]any
+
+// assertDBState asserts that the tables in the database state fixture of the case hold the expected rows, reporting
+// the failures to the given test. Only the columns in the fixture are compared, without the ignored ones. The rows are
+// ordered by the primary key of the table.
+func assertDBState(it *IntegrationTest, tb testing.TB, t *Data) {
+	if strings.TrimSpace(t.ExpectDBState) == "" {
+		return
+	}
+
+	expected, err := it.Fixtures.ReadDBStateE(t.ExpectDBState)
+	require.NoError(tb, err)
+
+	db, err := it.sqlDB()
+	require.NoError(tb, err)
+
+	tables := make([]string, 0, len(expected))
+	for table := range expected {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	for _, table := range tables {
+		columns := stateColumns(table, expected[table], t.IgnoreDBColumns)
+
+		rows, err := queryTable(it.ctx, db, table, columns)
+		require.NoError(tb, err)
+
+		want, err := projectRows(expected[table], columns)
+		require.NoError(tb, err)
+
+		got, err := projectRows(rows, columns)
+		require.NoError(tb, err)
+
+		require.Equal(tb, want, got, "rows of table '%s' do not match '%s'", table, t.ExpectDBState)
+	}
+}
+
+// stateColumns returns the columns of the table that are in any of the expected rows, except for the ignored ones. A
+// column is ignored by its name, for all tables, or by 'table.column'.
+func stateColumns(table string, rows []map[string]any, ignore []string) []string {
+	ignored := make(map[string]bool, len(ignore))
+	for _, column := range ignore {
+		ignored[column] = true
+	}
+
+	set := make(map[string]bool)
+	for _, row := range rows {
+		for column := range row {
+			if !ignored[column] && !ignored[table+"."+column] {
+				set[column] = true
+			}
+		}
+	}
+
+	columns := make([]string, 0, len(set))
+	for column := range set {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	return columns
+}
+
+// queryTable returns all rows of the table, ordered by its primary key, or by the given columns when it has none.
+func queryTable(ctx context.Context, db *sql.DB, table string, columns []string) ([]map[string]any, error) {
+	order, err := primaryKey(ctx, db, table)
+	if err != nil {
+		return nil, err
+	}
+	if len(order) == 0 {
+		order = columns
+	}
+
+	query := fmt.Sprintf("SELECT row_to_json(t)::text FROM %s t", quoteTable(table))
+	if len(order) > 0 {
+		quoted := make([]string, len(order))
+		for i, column := range order {
+			quoted[i] = "t." + pq.QuoteIdentifier(column)
+		}
+		query += " ORDER BY " + strings.Join(quoted, ", ")
+	}
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("could not query table '%s': %w", table, err)
+	}
+	defer rows.Close()
+
+	var result []map[string]any
+	for rows.Next() {
+		var buf string
+		if err = rows.Scan(&buf); err != nil {
+			return nil, fmt.Errorf("could not query table '%s': %w", table, err)
+		}
+
+		var row map[string]any
+		if err = json.Unmarshal([]byte(buf), &row); err != nil {
+			return nil, fmt.Errorf("could not query table '%s': %w", table, err)
+		}
+
+		for _, column := range columns {
+			if _, ok := row[column]; !ok {
+				return nil, fmt.Errorf("column '%s' does not exist in table '%s'", column, table)
+			}
+		}
+
+		result = append(result, row)
+	}
+
+	return result, rows.Err()
+}
+
+// primaryKey returns the columns of the primary key of the table, in the order of the key.
+func primaryKey(ctx context.Context, db *sql.DB, table string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT a.attname
+		FROM pg_index i
+		JOIN pg_attribute a ON a.attrelid = i.indrelid AND a.attnum = ANY(i.indkey)
+		WHERE i.indrelid = $1::text::regclass AND i.indisprimary
+		ORDER BY array_position(i.indkey::int2[], a.attnum)`,
+		quoteTable(table),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the primary key of table '%s': %w", table, err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err = rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("could not find the primary key of table '%s': %w", table, err)
+		}
+
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
+
+// quoteTable quotes a table name, which may be qualified by its schema, like 'public.visits'.
+func quoteTable(table string) string {
+	parts := strings.Split(table, ".")
+	for i, part := range parts {
+		parts[i] = pq.QuoteIdentifier(part)
+	}
+
+	return strings.Join(parts, ".")
+}
+
+// projectRows keeps only the given columns of the rows. The values are normalized through JSON, so that the values
+// of a fixture and the ones of the database compare equal, like an integer and a float with the same value.
+func projectRows(rows []map[string]any, columns []string) ([]map[string]any, error) {
+	projected := make([]map[string]any, len(rows))
+	for i, row := range rows {
+		projected[i] = make(map[string]any, len(columns))
+		for _, column := range columns {
+			projected[i][column] = row[column]
+		}
+	}
+
+	buf, err := json.Marshal(projected)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized []map[string]any
+	err = json.Unmarshal(buf, &normalized)
+
+	return normalized, err
+}
@@ -0,0 +1,127 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// requireDockerEnv is the environment variable that makes the integration tests fail, instead of skipping them,
+	// when the Docker daemon cannot be reached.
+	requireDockerEnv = "ECHOPROBE_REQUIRE_DOCKER"
+
+	// dockerProbeTimeout limits the time it takes to find out that the Docker daemon does not respond.
+	dockerProbeTimeout = 5 * time.Second
+)
+
+// ErrDockerUnavailable is returned when an option needs Docker, but the Docker daemon cannot be reached.
+// NewIntegrationTest skips the test on it, unless the ECHOPROBE_REQUIRE_DOCKER environment variable is set.
+var ErrDockerUnavailable = errors.New("docker is not available")
+
+// dockerOption is implemented by the options that start containers.
+type dockerOption interface {
+	usesDocker() bool
+}
+
+// dockerProbe finds out whether the Docker daemon can be reached, once for all the options of an integration test or
+// a suite.
+type dockerProbe struct {
+	once sync.Once
+	err  error
+}
+
+// reachable returns why the Docker daemon cannot be reached, or nil when it can.
+func (p *dockerProbe) reachable(ctx context.Context) error {
+	p.once.Do(func() {
+		p.err = dockerReachable(ctx)
+	})
+
+	return p.err
+}
+
+// requireDocker reports whether the integration tests have to fail when the Docker daemon cannot be reached.
+func requireDocker() bool {
+	required, _ := strconv.ParseBool(os.Getenv(requireDockerEnv))
+
+	return required
+}
+
+// checkDocker returns an ErrDockerUnavailable when any of the options starts containers and the Docker daemon cannot
+// be reached, so that none of them is set up in vain.
+func checkDocker(ctx context.Context, probe *dockerProbe, opts []IntegrationTestOption) error {
+	for _, o := range opts {
+		if d, ok := o.(dockerOption); !ok || !d.usesDocker() {
+			continue
+		}
+
+		err := probe.reachable(ctx)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrDockerUnavailable, err)
+		}
+
+		return nil
+	}
+
+	return nil
+}
+
+// dockerReachable returns why the Docker daemon cannot be reached, or nil when it can.
+func dockerReachable(ctx context.Context) (err error) {
+	// testcontainers panics when it finds no Docker host at all.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	provider, err := testcontainers.NewDockerProvider()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dockerProbeTimeout)
+	defer cancel()
+
+	// The provider is closed by the health check.
+	return provider.Health(ctx)
+}
+
+func (o IntegrationTestWithPostgres) usesDocker() bool {
+	switch o.Backend {
+	case PostgresBackendLocal:
+		return false
+	case PostgresBackendAuto:
+		return !o.runsLocally()
+	default:
+		return true
+	}
+}
+
+func (o IntegrationTestWithMySQL) usesDocker() bool {
+	return true
+}
+
+func (o IntegrationTestWithBigQuery) usesDocker() bool {
+	return true
+}
@@ -16,93 +16,518 @@
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"log"
+	"strings"
 	"testing"
 
-	"github.com/docker/go-connections/nat"
 	"github.com/labstack/echo/v4"
+	"github.com/testcontainers/testcontainers-go/wait"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-// IntegrationTest is a struct that holds all the necessary information for integration testing.
+// IntegrationTest is a struct that holds all the necessary information for integration testing. Db holds the
+// *gorm.DB of the postgres or MySQL database, which Gorm returns without the type assertion. T is the test that the
+// integration test belongs to, it is nil when NewIntegrationTestE is given another testing.TB, like a *testing.B.
 type IntegrationTest struct {
-	T           *testing.T
-	Db          interface{}
-	Echo        *echo.Echo
-	Fixtures    *Fixtures
-	Container   *PostgresDBContainer
-	BqContainer *BigqueryEmulatorContainer
-	Mock        *Mock
+	T              *testing.T
+	Db             interface{}
+	Echo           *echo.Echo
+	Fixtures       *Fixtures
+	Container      *PostgresDBContainer
+	MySQLContainer *MySQLContainer
+	BqContainer    *BigqueryEmulatorContainer
+	Mock           *Mock
 
-	opts []IntegrationTestOption
+	// tb is the test, benchmark or fuzz test that the failures are reported to.
+	tb        testing.TB
+	ctx       context.Context
+	opts      []IntegrationTestOption
+	tornDown  bool
+	resetDB   func(ctx context.Context) error
+	resources resources
+	db        databases
+	bq        bigqueryClients
+	queries   queryRecorder
+	docker    dockerProbe
 }
 
-// NewIntegrationTest prepares database for integration testing.
+// NewIntegrationTest prepares database for integration testing. The teardown of the integration test is registered
+// with t.Cleanup, so it runs even when the setup of one of the options fails halfway through.
+//
+// When an option needs Docker and the Docker daemon cannot be reached, the test is skipped. With the
+// ECHOPROBE_REQUIRE_DOCKER environment variable set, like in CI, it fails instead.
 func NewIntegrationTest(t *testing.T, opts ...IntegrationTestOption) *IntegrationTest {
+	t.Helper()
+
+	it, err := NewIntegrationTestE(context.Background(), t, opts...)
+	if errors.Is(err, ErrDockerUnavailable) && !requireDocker() {
+		t.Skipf("%v, set %s=true to fail instead", err, requireDockerEnv)
+	}
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	return it
+}
+
+// NewIntegrationTestE prepares database for integration testing like NewIntegrationTest, but returns the errors
+// instead of failing the test. The context is passed to the setup of every option, so a deadline on it limits the
+// time the containers get to start. When tb is not nil, the teardown is registered with tb.Cleanup. Without it, for
+// example in TestMain, the caller has to call TearDownE.
+//
+// When the setup of an option fails, the options that were set up already are torn down before returning. When an
+// option needs Docker and the Docker daemon cannot be reached, none of them is set up and an ErrDockerUnavailable is
+// returned.
+func NewIntegrationTestE(ctx context.Context, tb testing.TB, opts ...IntegrationTestOption) (*IntegrationTest, error) {
+	t, _ := tb.(*testing.T)
+
 	it := &IntegrationTest{
 		T:        t,
+		tb:       tb,
 		Echo:     echo.New(),
-		Fixtures: &Fixtures{},
+		Fixtures: newFixtures(tb),
+		// The teardown keeps the values of the context, but runs even after it was cancelled.
+		ctx: context.WithoutCancel(ctx),
 	}
 
-	for _, o := range opts {
-		o.setup(it)
+	if tb != nil {
+		tb.Cleanup(it.TearDown)
 	}
 
-	it.opts = opts
+	err := checkDocker(ctx, &it.docker, opts)
+	if err != nil {
+		return nil, err
+	}
 
-	return it
+	for _, o := range fixturesFirst(opts) {
+		// The option is registered before its setup, so that whatever it managed to start is torn down as well.
+		it.opts = append(it.opts, o)
+
+		err := o.Setup(ctx, it)
+		if err != nil {
+			err = fmt.Errorf("%s setup: %w", optionName(o), err)
+			return nil, errors.Join(err, it.TearDownE(it.ctx))
+		}
+	}
+
+	return it, nil
 }
 
-// TearDown cleans up the database after integration testing.
+// TearDown cleans up after integration testing. The options are torn down in the reverse order of their setup and
+// all the errors that occur are reported together. TearDown is called automatically through t.Cleanup, calling it
+// explicitly is still allowed and only the first call has an effect.
 func (it *IntegrationTest) TearDown() {
-	for _, o := range it.opts {
-		o.tearDown(it)
+	err := it.TearDownE(it.ctx)
+	if err == nil {
+		return
+	}
+
+	if it.tb == nil {
+		log.Printf("error detected during teardown: %v", err)
+		return
+	}
+
+	it.tb.Errorf("error detected during teardown: %v", err)
+}
+
+// TearDownE cleans up after integration testing like TearDown, but returns the errors instead of reporting them to
+// the test.
+func (it *IntegrationTest) TearDownE(ctx context.Context) error {
+	if it.tornDown {
+		return nil
+	}
+	it.tornDown = true
+
+	var errs []error
+	for i := len(it.opts) - 1; i >= 0; i-- {
+		if err := it.opts[i].TearDown(ctx, it); err != nil {
+			errs = append(errs, fmt.Errorf("%s teardown: %w", optionName(it.opts[i]), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// logf logs to the test, or to the standard logger when the integration test does not belong to one.
+func (it *IntegrationTest) logf(format string, args ...any) {
+	if it.tb == nil {
+		log.Printf(format, args...)
+		return
+	}
+
+	it.tb.Helper()
+	it.tb.Logf(format, args...)
+}
+
+// dumpLogs logs the last lines of the container logs when the test failed.
+func (it *IntegrationTest) dumpLogs(logs *containerLogs) {
+	if it.tb != nil && it.tb.Failed() {
+		logs.dump(it.logf)
+	}
+}
+
+// fail reports the error to the test and stops it, or panics when the integration test does not belong to one. It is
+// only used by the accessors of the integration test, which run on the goroutine of the test itself, since FailNow
+// cannot be called from the ones of its subtests.
+func (it *IntegrationTest) fail(err error) {
+	if it.tb == nil {
+		panic(err)
+	}
+
+	it.tb.Helper()
+	it.tb.Fatal(err.Error())
+}
+
+// fixturesFirst moves the IntegrationTestWithFixtures options in front of the others, since those read fixtures.
+func fixturesFirst(opts []IntegrationTestOption) []IntegrationTestOption {
+	sorted := make([]IntegrationTestOption, 0, len(opts))
+	for _, o := range opts {
+		if _, ok := o.(IntegrationTestWithFixtures); ok {
+			sorted = append(sorted, o)
+		}
+	}
+
+	for _, o := range opts {
+		if _, ok := o.(IntegrationTestWithFixtures); !ok {
+			sorted = append(sorted, o)
+		}
 	}
+
+	return sorted
 }
 
-// IntegrationTestOption is an interface for integration test options.
+// optionName returns the name of the type of the option, to tell which option an error comes from.
+func optionName(o IntegrationTestOption) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", o), "echoprobe.")
+}
+
+// IntegrationTestOption is an interface for integration test options. Besides the options of this package, it can be
+// implemented to integrate any other dependency, like Redis or Kafka, with an integration test. Setup is called by
+// NewIntegrationTest and TearDown when the test is torn down, in the reverse order of the setup. TearDown is also
+// called after a failed Setup, so it should release whatever the option managed to start. Options can keep their
+// resources on the integration test with SetResource.
 type IntegrationTestOption interface {
-	setup(*IntegrationTest)
-	tearDown(*IntegrationTest)
+	Setup(ctx context.Context, it *IntegrationTest) error
+	TearDown(ctx context.Context, it *IntegrationTest) error
 }
 
 // IntegrationTestWithPostgres is an option for integration testing that sets up a postgres database test container.
 // In the InitSQLScript a SQL script filename can be passed to initialize the database. The script should be located
-// under a 'fixtures' directory where the _test.go file is located. An optional gorm config can also be passed.
+// under a 'fixtures' directory where the _test.go file is located, or in the fixtures of IntegrationTestWithFixtures.
+// An optional gorm config can also be passed. Services that do not use gorm can set SkipGorm, and connect through
+// it.SQLDB, it.PgxPool or it.DSN instead.
+//
+// The init script stops at the first statement that fails, failing the setup with the line number and the error of
+// postgres. Set InitSQLTransaction to run the whole script in a single transaction. The output of psql is logged to
+// the test.
+//
+// By default, all the cases run by AssertAll share the same database. When IsolateCases is set, the database is reset
+// to its state right after the init script before every case, so that the cases cannot affect each other.
+//
+// The container runs the 'postgres:latest' image, unless another Image is given, like a pinned version, PostGIS or
+// TimescaleDB. Env adds environment variables to the container, and Settings passes server settings, like
+// 'fsync=off', as '-c' flags to postgres. Tmpfs keeps the data directory in memory, which speeds up writes. The
+// Extensions are created right after startup, before the init script runs.
+//
+// The migrations in MigrationsDir are applied after the extensions and before the init script, ordered by their
+// version. Both the golang-migrate convention, '1_create_users.up.sql', and the goose convention, '1_create_users.sql'
+// with '-- +goose Up' annotations, are understood. The directory is relative to the 'fixtures' directory, or to the
+// root of MigrationsFS when it is set, like os.DirFS("../migrations") or an embed.FS of the migrations of the
+// application. The applied migrations are recorded in Container.Migrations.
+//
+// The last lines of the container logs are logged to the test when the setup or the test fails. StreamLogs logs every
+// line as it comes instead.
+//
+// By default, postgres runs in a container when the Docker daemon can be reached, and otherwise as a local process
+// from the postgres binaries installed on the machine, with its data in a temporary directory and on a random port.
+// Backend forces either of them. The binaries are looked up through pg_config and the PATH, unless LocalBinDir names
+// their directory. The local backend ignores the Image and Tmpfs, does not reuse anything across test runs, and leaves
+// Container.Container nil. So with an Image or Tmpfs, the default never falls back to it.
+//
+// ExportEnv names the environment variables that the connection details are exported to with t.Setenv, like
+// DATABASE_URL, so that the configuration of the application under test picks them up. Like t.Setenv, it cannot be
+// used in parallel tests.
 type IntegrationTestWithPostgres struct {
-	InitSQLScript string
-	Config        *gorm.Config
+	InitSQLScript      string
+	InitSQLTransaction bool
+	Config             *gorm.Config
+	IsolateCases       bool
+	Image              string
+	Env                map[string]string
+	Settings           []string
+	Tmpfs              bool
+	Extensions         []string
+	MigrationsDir      string
+	MigrationsFS       fs.FS
+	SkipGorm           bool
+	StreamLogs         bool
+	Backend            PostgresBackend
+	LocalBinDir        string
+	ExportEnv          DBEnv
 }
 
-func (o IntegrationTestWithPostgres) setup(it *IntegrationTest) {
-	// sanity check
-	if o.Config == nil {
-		o.Config = &gorm.Config{}
+// Setup starts the postgres container, or the local postgres process, and connects gorm to it. When the setup fails,
+// the last lines of the container logs are logged to the test.
+func (o IntegrationTestWithPostgres) Setup(ctx context.Context, it *IntegrationTest) (err error) {
+	logs := newContainerLogs("postgres")
+	if o.StreamLogs {
+		logs.startStreaming(it.logf)
 	}
+	defer func() {
+		if err != nil {
+			logs.dump(it.logf)
+			logs.stopStreaming()
+		}
+	}()
 
-	dbContainer, err := setupPostgresDB(context.Background(), o.InitSQLScript)
+	backend, err := o.backend(ctx, &it.docker)
+	if err != nil {
+		return fmt.Errorf("database setup error: %w", err)
+	}
+
+	if backend == PostgresBackendDocker && reuseContainers() {
+		err = o.setupReused(ctx, it, logs)
+	} else {
+		err = o.setup(ctx, it, backend, logs)
+	}
 	if err != nil {
-		it.T.Fatalf("database setup error: %v", err)
+		return err
+	}
+
+	it.setenv(o.ExportEnv.postgres(it.Container))
+
+	return nil
+}
+
+// setup starts a new postgres database with the backend and initializes it.
+func (o IntegrationTestWithPostgres) setup(
+	ctx context.Context, it *IntegrationTest, backend PostgresBackend, logs *containerLogs,
+) error {
+	dbContainer, err := startPostgresDB(ctx, o, backend, logs)
+	if err != nil {
+		return fmt.Errorf("database setup error: %w", err)
 	}
 
 	it.Container = dbContainer
 
-	dsn := dbURL(dbContainer.DBHost, nat.Port(fmt.Sprintf("%d/tcp", dbContainer.DBPort)))
-	db, err := gorm.Open(postgres.Open(dsn), o.Config)
+	err = dbContainer.initDatabase(ctx, it.Fixtures, o, dbContainer.DBName, it.logf)
 	if err != nil {
-		it.T.Fatalf("database connection error: %v", err)
+		return fmt.Errorf("database setup error: %w", err)
 	}
 
-	it.Db = db
+	if o.IsolateCases {
+		// The snapshot has to be taken before gorm connects, since postgres only copies databases that are not in use.
+		err = dbContainer.createDatabase(ctx, dbSnapshotName, dbContainer.DBName)
+		if err != nil {
+			return fmt.Errorf("database snapshot error: %w", err)
+		}
+
+		it.resetDB = func(ctx context.Context) error {
+			return dbContainer.restoreDatabase(ctx, dbContainer.DBName, dbSnapshotName)
+		}
+	}
+
+	return it.connect(o)
 }
 
-func (o IntegrationTestWithPostgres) tearDown(it *IntegrationTest) {
-	err := it.Container.Terminate(context.Background())
+// setupReused starts the reused postgres container, or reattaches to it, and creates the database of the integration
+// test as a copy of its template database.
+func (o IntegrationTestWithPostgres) setupReused(ctx context.Context, it *IntegrationTest, logs *containerLogs) error {
+	dbContainer, err := reusePostgresDB(ctx, it.Fixtures, o, logs, it.logf)
 	if err != nil {
-		it.T.Logf("error detected during container termination: %v", err)
+		return fmt.Errorf("database setup error: %w", err)
 	}
+
+	name := randomDatabaseName()
+
+	err = dbContainer.createDatabase(ctx, name, dbTemplateName)
+	if err != nil {
+		return fmt.Errorf("database setup error: %w", err)
+	}
+
+	dbContainer.DBName = name
+	it.Container = dbContainer
+
+	if o.IsolateCases {
+		it.resetDB = func(ctx context.Context) error {
+			return dbContainer.restoreDatabase(ctx, name, dbTemplateName)
+		}
+	}
+
+	return it.connect(o)
+}
+
+// TearDown closes the database connections and terminates the postgres container, or stops the local postgres
+// process. A reused container keeps running, only the database of the integration test is dropped. When the test
+// failed, the last lines of the container logs are logged to it.
+func (o IntegrationTestWithPostgres) TearDown(ctx context.Context, it *IntegrationTest) error {
+	if it.Container == nil {
+		return nil
+	}
+
+	it.dumpLogs(it.Container.logs)
+	it.Container.logs.stopStreaming()
+	it.db.close()
+
+	if it.Container.reused() {
+		err := it.Container.dropDatabase(ctx, it.Container.DBName)
+		if err != nil {
+			return fmt.Errorf("postgres database removal: %w", err)
+		}
+
+		return nil
+	}
+
+	err := it.Container.terminate(ctx)
+	if err != nil {
+		return fmt.Errorf("postgres container termination: %w", err)
+	}
+
+	return nil
+}
+
+// ResetDB restores the postgres database to its state right after the init script. AssertAll calls it before every
+// case when IntegrationTestWithPostgres.IsolateCases is set. A failed reset is reported to the test without stopping
+// it, so that it can be called from its subtests as well. Custom assertion loops that run their cases as subtests can
+// use ResetDBE instead, to report the failure to the case.
+func (it *IntegrationTest) ResetDB() {
+	err := it.ResetDBE(it.ctx)
+	if err != nil {
+		it.tb.Helper()
+		it.tb.Error(err.Error())
+	}
+}
+
+// ResetDBE restores the postgres database like ResetDB, but returns the errors instead of reporting them to the test.
+func (it *IntegrationTest) ResetDBE(ctx context.Context) error {
+	if it.resetDB == nil {
+		return errors.New("database reset error: IsolateCases is not enabled")
+	}
+
+	// The idle connections would point to the dropped database, so they are closed beforehand. The settings of the
+	// pool, which the application under test may have configured, are left as they are.
+	sqlDB, pool := it.db.connections()
+	if sqlDB != nil {
+		err := closeIdleConns(ctx, sqlDB)
+		if err != nil {
+			return fmt.Errorf("database reset error: %w", err)
+		}
+	}
+
+	err := it.resetDB(ctx)
+	if err != nil {
+		return fmt.Errorf("database reset error: %w", err)
+	}
+
+	if pool != nil {
+		pool.Reset()
+	}
+
+	return nil
+}
+
+// resetDBFor resets the postgres database, reporting the failures to the given test. It is called on the goroutine of
+// that test, so the test is stopped when the reset fails.
+func (it *IntegrationTest) resetDBFor(tb testing.TB) {
+	err := it.ResetDBE(it.ctx)
+	if err != nil {
+		tb.Fatal(err.Error())
+	}
+}
+
+// openGorm opens a gorm connection to the given postgres database.
+func openGorm(dsn string, config *gorm.Config) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), config)
+	if err != nil {
+		return nil, fmt.Errorf("database connection error: %w", err)
+	}
+
+	return db, nil
+}
+
+// IntegrationTestWithMySQL is an option for integration testing that sets up a MySQL database test container, like
+// IntegrationTestWithPostgres does for postgres. The InitSQLScripts initialize the database, in the order they are
+// given. They are located under the 'fixtures' directory, like the init script of IntegrationTestWithPostgres, and each
+// of them is sent to the server as a whole, so the client-only DELIMITER command cannot be used. An optional gorm
+// config can also be passed.
+//
+// The container runs the 'mysql:8.4' image, unless another Image is given, like 'mariadb:11'. Env adds environment
+// variables to the container. By default, the setup waits until the server accepts connections, WaitingFor replaces
+// that strategy.
+//
+// The seeds, the database state assertions and the database accessors other than Gorm are only available for
+// postgres. The statements that gorm runs are recorded, so the query assertions of AssertAll work the same.
+//
+// The last lines of the container logs are logged to the test when the setup or the test fails. StreamLogs logs every
+// line as it comes instead. ExportEnv names the environment variables that the connection details are exported to, like
+// for IntegrationTestWithPostgres.
+type IntegrationTestWithMySQL struct {
+	InitSQLScripts []string
+	Config         *gorm.Config
+	Image          string
+	Env            map[string]string
+	WaitingFor     wait.Strategy
+	StreamLogs     bool
+	ExportEnv      DBEnv
+}
+
+// Setup starts the MySQL container, runs the init scripts and connects gorm to it. When the setup fails, the last
+// lines of the container logs are logged to the test.
+func (o IntegrationTestWithMySQL) Setup(ctx context.Context, it *IntegrationTest) (err error) {
+	logs := newContainerLogs("mysql")
+	if o.StreamLogs {
+		logs.startStreaming(it.logf)
+	}
+	defer func() {
+		if err != nil {
+			logs.dump(it.logf)
+			logs.stopStreaming()
+		}
+	}()
+
+	dbContainer, err := setupMySQLDB(ctx, o, logs)
+	if err != nil {
+		return fmt.Errorf("database setup error: %w", err)
+	}
+
+	it.MySQLContainer = dbContainer
+
+	err = dbContainer.initDatabase(ctx, it.Fixtures, o.InitSQLScripts)
+	if err != nil {
+		return fmt.Errorf("database setup error: %w", err)
+	}
+
+	err = it.connectMySQL(o)
+	if err != nil {
+		return err
+	}
+
+	it.setenv(o.ExportEnv.mysql(dbContainer))
+
+	return nil
+}
+
+// TearDown closes the database connections and terminates the MySQL container. When the test failed, the last lines
+// of the container logs are logged to it.
+func (o IntegrationTestWithMySQL) TearDown(ctx context.Context, it *IntegrationTest) error {
+	if it.MySQLContainer == nil {
+		return nil
+	}
+
+	it.dumpLogs(it.MySQLContainer.logs)
+	it.MySQLContainer.logs.stopStreaming()
+	it.db.close()
+
+	err := it.MySQLContainer.Terminate(ctx)
+	if err != nil {
+		return fmt.Errorf("mysql container termination: %w", err)
+	}
+
+	return nil
 }
 
 // IntegrationTestWithMocks is an option for integration testing that allows mocking
@@ -111,31 +536,86 @@ type IntegrationTestWithMocks struct {
 	BaseURL string
 }
 
-func (o IntegrationTestWithMocks) setup(it *IntegrationTest) {
+// Setup creates the Mock of the integration test.
+func (o IntegrationTestWithMocks) Setup(_ context.Context, it *IntegrationTest) error {
 	it.Mock = NewMock(o.BaseURL)
+	it.Mock.fixtures = it.Fixtures
+	it.Mock.t = it.tb
+
+	return nil
 }
 
-func (o IntegrationTestWithMocks) tearDown(it *IntegrationTest) {
-	it.Mock.TearDown()
+// TearDown removes the mocks of the integration test.
+func (o IntegrationTestWithMocks) TearDown(_ context.Context, it *IntegrationTest) error {
+	if it.Mock != nil {
+		it.Mock.TearDown()
+	}
+
+	return nil
 }
 
 // IntegrationTestWithBigQuery is an option for integration testing that sets up a BigQuery database test container.
+// The DataPath of the YAML data file is relative to the root of the fixtures, for example
+// 'fixtures/bigquery/data.yaml'. More data files can be passed as DataPaths, and DataDir adds the YAML files in a
+// directory, in the order of their names. The data files are merged at startup, so the projects and datasets can be
+// spread over several of them.
+//
+// The emulator runs with the 'test' project, unless another Project is given, which is available as
+// BqContainer.Project. Flags adds flags to the command of the emulator, like '--log-level=debug'.
+//
+// The last lines of the container logs are logged to the test when the setup or the test fails. StreamLogs logs every
+// line as it comes instead. ExportEnv names the environment variables that the addresses of the emulator are exported
+// to with t.Setenv, like BIGQUERY_EMULATOR_HOST. The clients returned by it.BigQueryClient and it.BigQueryReadClient
+// are connected to the emulator.
 type IntegrationTestWithBigQuery struct {
-	DataPath string
+	DataPath   string
+	DataPaths  []string
+	DataDir    string
+	Project    string
+	Flags      []string
+	StreamLogs bool
+	ExportEnv  BigQueryEnv
 }
 
-func (o IntegrationTestWithBigQuery) setup(it *IntegrationTest) {
-	container, err := setupBigqueryEmulator(context.Background(), o.DataPath)
+// Setup starts the BigQuery emulator container. When the setup fails, the last lines of the container logs are logged
+// to the test.
+func (o IntegrationTestWithBigQuery) Setup(ctx context.Context, it *IntegrationTest) error {
+	logs := newContainerLogs("bigquery")
+	if o.StreamLogs {
+		logs.startStreaming(it.logf)
+	}
+
+	container, err := setupBigqueryEmulator(ctx, it.Fixtures, o, logs)
 	if err != nil {
-		it.T.Fatalf("database setup error: %v", err)
+		logs.dump(it.logf)
+		logs.stopStreaming()
+		return fmt.Errorf("database setup error: %w", err)
 	}
 
 	it.BqContainer = container
+	it.setenv(o.ExportEnv.values(container))
+
+	return nil
 }
 
-func (o IntegrationTestWithBigQuery) tearDown(it *IntegrationTest) {
-	err := it.BqContainer.Terminate(context.Background())
-	if err != nil {
-		it.T.Logf("error detected during container termination: %v", err)
+// TearDown closes the BigQuery clients and terminates the BigQuery emulator container. When the test failed, the last
+// lines of the container logs are logged to it.
+func (o IntegrationTestWithBigQuery) TearDown(ctx context.Context, it *IntegrationTest) error {
+	if it.BqContainer == nil {
+		return nil
 	}
+
+	it.dumpLogs(it.BqContainer.logs)
+	it.BqContainer.logs.stopStreaming()
+
+	var errs []error
+	if err := it.bq.close(); err != nil {
+		errs = append(errs, fmt.Errorf("bigquery client close: %w", err))
+	}
+
+	if err := it.BqContainer.Terminate(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("bigquery container termination: %w", err))
+	}
+
+	return errors.Join(errs...)
 }
@@ -0,0 +1,104 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// DBEnv names the environment variables that the connection details of a database are exported to, for the
+// configuration loader of the application under test to pick them up. A detail with an empty name is not exported.
+// DSN is the URL of a postgres database, like it.DSN, or the data source name of the MySQL driver.
+type DBEnv struct {
+	DSN      string
+	Host     string
+	Port     string
+	Name     string
+	Username string
+	Password string
+}
+
+// BigQueryEnv names the environment variables that the connection details of the BigQuery emulator are exported to.
+// Host and GRPCHost are the 'host:port' addresses of the REST and the gRPC API, Endpoint is the URL of the REST API.
+// A detail with an empty name is not exported.
+type BigQueryEnv struct {
+	Host     string
+	GRPCHost string
+	Endpoint string
+	Project  string
+}
+
+// postgres returns the values of the variables for the database of the postgres container.
+func (e DBEnv) postgres(c *PostgresDBContainer) map[string]string {
+	return e.values(c.dsn(c.DBName), c.DBHost, c.DBPort, c.DBName, c.DBUsername, c.DBPassword)
+}
+
+// mysql returns the values of the variables for the database of the MySQL container.
+func (e DBEnv) mysql(c *MySQLContainer) map[string]string {
+	return e.values(c.dsn(), c.DBHost, c.DBPort, c.DBName, c.DBUsername, c.DBPassword)
+}
+
+// values returns the values of the variables that have a name.
+func (e DBEnv) values(dsn, host string, port int, name, username, password string) map[string]string {
+	return namedValues(map[string]string{
+		e.DSN:      dsn,
+		e.Host:     host,
+		e.Port:     strconv.Itoa(port),
+		e.Name:     name,
+		e.Username: username,
+		e.Password: password,
+	})
+}
+
+// values returns the values of the variables for the BigQuery emulator container.
+func (e BigQueryEnv) values(c *BigqueryEmulatorContainer) map[string]string {
+	host := fmt.Sprintf("%s:%d", c.BqHost, c.BqRestPort)
+
+	return namedValues(map[string]string{
+		e.Host:     host,
+		e.GRPCHost: fmt.Sprintf("%s:%d", c.BqHost, c.BqGrpcPort),
+		e.Endpoint: "http://" + host,
+		e.Project:  c.Project,
+	})
+}
+
+// namedValues drops the value of the details that are not exported.
+func namedValues(vars map[string]string) map[string]string {
+	delete(vars, "")
+
+	return vars
+}
+
+// setenv sets the environment variables for the duration of the test, which restores them when it completes. Without
+// a test, they stay set.
+func (it *IntegrationTest) setenv(vars map[string]string) {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if it.tb != nil {
+			it.tb.Setenv(key, vars[key])
+			continue
+		}
+
+		_ = os.Setenv(key, vars[key])
+	}
+}
@@ -15,65 +15,316 @@
 package echoprobe
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io/fs"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
 )
 
-// Fixtures is a helper for reading fixtures.
-type Fixtures struct{}
+// update is the flag that makes the assertions write the actual responses back to their fixtures. It is namespaced,
+// since test packages commonly define an 'update' flag of their own for their golden files.
+var update = flag.Bool("echoprobe.update", false, "update the response fixtures with the actual responses")
+
+// updateEnv is the environment variable that enables the update mode, just like the update flag.
+const updateEnv = "ECHOPROBE_UPDATE"
+
+// updateFixtures reports whether the response fixtures are to be updated, instead of asserted.
+func updateFixtures() bool {
+	if *update {
+		return true
+	}
+
+	enabled, _ := strconv.ParseBool(os.Getenv(updateEnv))
+
+	return enabled
+}
+
+// Fixtures is a helper for reading fixtures. The fixtures are read from the 'fixtures' directory in the root of FS.
+// When FS is nil, the root is the directory of the test file that created the integration test.
+//
+// The Read methods report a fixture that cannot be read to the test that owns the integration test and return the zero
+// value. The test is marked as failed, but not stopped, since the methods may be called from one of its subtests. The
+// methods with the E suffix return the error instead, for the subtest to report it.
+type Fixtures struct {
+	FS fs.FS
+
+	// dir is the directory on disk that the root of the fixtures is in, if there is one. For the default root, it is
+	// resolved once by NewIntegrationTest, since the call stack of the goroutines started later on, like the ones of
+	// subtests, does not lead back to the test file anymore.
+	dir string
+
+	// t is the test that the errors are reported to.
+	t testing.TB
+}
+
+// newFixtures creates a Fixtures for the test file that called this function.
+func newFixtures(t testing.TB) *Fixtures {
+	dir, _ := testpath()
+
+	return &Fixtures{
+		dir: dir,
+		t:   t,
+	}
+}
+
+// root returns the filesystem the fixtures are read from.
+func (f Fixtures) root() (fs.FS, error) {
+	if f.FS != nil {
+		return f.FS, nil
+	}
+
+	if f.dir != "" {
+		return os.DirFS(f.dir), nil
+	}
+
+	dir, err := testpath()
+	if err != nil {
+		return nil, fmt.Errorf("%w, use IntegrationTestWithFixtures to set the location of the fixtures", err)
+	}
+
+	return os.DirFS(dir), nil
+}
+
+// resolve returns the filesystem that holds the named file or directory, its name in that filesystem and the
+// directory on disk of the root of that filesystem, if there is one. An fs.FS cannot leave its root, so for fixtures on
+// disk, a name like '../testdata/data.yaml' is resolved relative to their directory instead.
+func (f Fixtures) resolve(name string) (fs.FS, string, string, error) {
+	name = path.Clean(strings.TrimPrefix(name, "/"))
+
+	if f.dir != "" && (name == ".." || strings.HasPrefix(name, "../")) {
+		file := filepath.Join(f.dir, filepath.FromSlash(name))
+		dir := filepath.Dir(file)
+
+		return os.DirFS(dir), filepath.Base(file), dir, nil
+	}
+
+	root, err := f.root()
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return root, name, f.dir, nil
+}
+
+// readFile reads a file from the root of the fixtures. The name is a slash-separated path, like 'fixtures/init.sql'.
+func (f Fixtures) readFile(name string) ([]byte, error) {
+	root, file, dir, err := f.resolve(name)
+	if err != nil {
+		return nil, fmt.Errorf("could not read fixture '%s': %w", name, err)
+	}
+
+	buf, err := fs.ReadFile(root, file)
+	if err != nil {
+		return nil, readError(root, file, dir, err)
+	}
+
+	return buf, nil
+}
+
+// readError describes a fixture that could not be read from the root, which is in the directory on disk, if there is
+// one. For a missing fixture, it lists the directory that was searched and the fixtures in it with a similar name.
+func readError(root fs.FS, name, rootDir string, err error) error {
+	if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not read fixture '%s': %w", name, err)
+	}
+
+	dir := path.Dir(name)
+	location := fmt.Sprintf("'%s' of %T", dir, root)
+	if rootDir != "" {
+		location = fmt.Sprintf("'%s'", filepath.Join(rootDir, filepath.FromSlash(dir)))
+	}
+
+	var names []string
+	entries, _ := fs.ReadDir(root, dir)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+
+	matches := closeMatches(path.Base(name), names)
+	if len(matches) == 0 {
+		return fmt.Errorf("could not read fixture '%s', searched in %s: %w", name, location, err)
+	}
+
+	return fmt.Errorf(
+		"could not read fixture '%s', searched in %s, did you mean '%s'?: %w",
+		name, location, strings.Join(matches, "', '"), err,
+	)
+}
+
+// fail reports the error to the test, or panics when the fixtures do not belong to one. The test is not stopped, since
+// FailNow may only be called from the goroutine of the test itself, not from the ones of its subtests.
+func (f Fixtures) fail(err error) {
+	if f.t == nil {
+		panic(err)
+	}
+
+	f.t.Helper()
+	f.t.Error(err.Error())
+}
 
 // ReadResponse reads the response from a file.
 func (f Fixtures) ReadResponse(s string) string {
-	return f.ReadFixture(s+".json", "responses")
+	content, err := f.ReadResponseE(s)
+	if err != nil {
+		f.fail(err)
+	}
+
+	return content
+}
+
+// ReadResponseE reads the response from a file.
+func (f Fixtures) ReadResponseE(s string) (string, error) {
+	return f.ReadFixtureE(s+".json", "responses")
 }
 
 // ReadRequestBody reads the request body from a file.
 func (f Fixtures) ReadRequestBody(s string) string {
-	return f.ReadFixture(s+".json", "requests")
+	content, err := f.ReadRequestBodyE(s)
+	if err != nil {
+		f.fail(err)
+	}
+
+	return content
+}
+
+// ReadRequestBodyE reads the request body from a file.
+func (f Fixtures) ReadRequestBodyE(s string) (string, error) {
+	return f.ReadFixtureE(s+".json", "requests")
 }
 
 // ReadExcelFile reads an excel file with xlsx extension.
 func (f Fixtures) ReadExcelFile(s string) map[string][][]string {
-	content := f.ReadFixture(s+".xlsx", "excel")
-	buf := []byte(content)
-	file, err := f.ExcelToMap(buf)
+	file, err := f.ReadExcelFileE(s)
 	if err != nil {
-		log.Fatalf("could not load excel file '%s': %v", s, err)
+		f.fail(err)
 	}
+
 	return file
 }
 
-// ReadExcelFile reads an excel file with xlsx extension.
+// ReadExcelFileE reads an excel file with xlsx extension.
+func (f Fixtures) ReadExcelFileE(s string) (map[string][][]string, error) {
+	content, err := f.ReadFixtureE(s+".xlsx", "excel")
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := f.ExcelToMap([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not load excel file '%s': %w", s, err)
+	}
+
+	return file, nil
+}
+
+// ReadCsvFile reads a csv file with csv extension.
 func (f Fixtures) ReadCsvFile(s string) string {
-	executionPath, err := testpath()
+	content, err := f.ReadCsvFileE(s)
+	if err != nil {
+		f.fail(err)
+	}
+
+	return content
+}
+
+// ReadCsvFileE reads a csv file with csv extension.
+func (f Fixtures) ReadCsvFileE(s string) (string, error) {
+	return f.ReadFixtureE(s+".csv", "csv")
+}
+
+// ReadDBState reads the expected state of database tables from a YAML or JSON file.
+func (f Fixtures) ReadDBState(s string) DBState {
+	state, err := f.ReadDBStateE(s)
 	if err != nil {
-		log.Fatalf("could not find test path: %v", err)
+		f.fail(err)
 	}
 
-	path := fmt.Sprintf("%s/fixtures/csv/%s.csv", executionPath, s)
-	buf, err := os.ReadFile(path)
+	return state
+}
+
+// ReadDBStateE reads the expected state of database tables from a YAML or JSON file. Unless the name has an
+// extension, the file is looked up with the '.yaml', '.yml' and '.json' extensions, in that order.
+func (f Fixtures) ReadDBStateE(s string) (DBState, error) {
+	content, _, err := f.readFixtureExt(s, "db", ".yaml", ".yml", ".json")
 	if err != nil {
-		log.Fatalf("could not read file '%s': %v", path, err)
+		return nil, err
+	}
+
+	return parseDBState(s, content)
+}
+
+// readFixtureExt reads a fixture that is looked up with each of the extensions, in order, unless its name has one of
+// them already. It returns the extension of the fixture that was found.
+func (f Fixtures) readFixtureExt(s, dir string, exts ...string) (string, string, error) {
+	for _, ext := range exts {
+		if path.Ext(s) == ext {
+			content, err := f.ReadFixtureE(s, dir)
+			return content, ext, err
+		}
+	}
+
+	var err error
+	for _, ext := range exts {
+		content, extErr := f.ReadFixtureE(s+ext, dir)
+		if extErr == nil {
+			return content, ext, nil
+		}
+
+		if !errors.Is(extErr, fs.ErrNotExist) {
+			return "", "", extErr
+		}
+
+		// The error of the first extension is reported, since it has the suggestions for a misspelled name.
+		if err == nil {
+			err = extErr
+		}
 	}
 
-	return string(buf)
+	return "", "", err
+}
+
+// parseDBState parses a database state fixture. JSON is a subset of YAML, so both are parsed as YAML.
+func parseDBState(s, content string) (DBState, error) {
+	var state DBState
+	err := yaml.Unmarshal([]byte(content), &state)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse database state '%s': %w", s, err)
+	}
+
+	return state, nil
 }
 
 // ReadFixture reads a fixture from a file.
 func (f Fixtures) ReadFixture(filename, dir string) string {
-	executionPath, err := testpath()
+	content, err := f.ReadFixtureE(filename, dir)
 	if err != nil {
-		log.Fatalf("could not file test path: %v", err)
+		f.fail(err)
 	}
 
-	path := fmt.Sprintf("%s/fixtures/%s/%s", executionPath, dir, filename)
-	buf, err := os.ReadFile(path)
+	return content
+}
+
+// ReadFixtureE reads a fixture from a file.
+func (f Fixtures) ReadFixtureE(filename, dir string) (string, error) {
+	buf, err := f.readFile(path.Join("fixtures", dir, filename))
 	if err != nil {
-		log.Fatalf("could not read file '%s': %v", path, err)
+		return "", err
 	}
 
-	return string(buf)
+	return string(buf), nil
 }
 
 func (f Fixtures) ExcelToMap(content []byte) (map[string][][]string, error) {
@@ -84,3 +335,78 @@ func (f Fixtures) ExcelToMap(content []byte) (map[string][][]string, error) {
 
 	return excelToMap(file)
 }
+
+// WriteFixture writes a fixture to a file, creating the file and its directory when they do not exist.
+// Only fixtures that are on disk can be written, so it fails for an FS without a directory, like an embed.FS.
+func (f Fixtures) WriteFixture(filename, dir string, content []byte) error {
+	if f.FS != nil && f.dir == "" {
+		return errors.New("fixtures can only be written to a directory on disk")
+	}
+
+	executionPath := f.dir
+	if executionPath == "" {
+		var err error
+		executionPath, err = testpath()
+		if err != nil {
+			return err
+		}
+	}
+
+	file := filepath.Join(executionPath, "fixtures", dir, filename)
+
+	err := os.MkdirAll(filepath.Dir(file), 0755)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(file, content, 0644)
+}
+
+// WriteResponse writes the body of a response to the fixture of the given response type. JSON is pretty-printed.
+func (f Fixtures) WriteResponse(s, responseType string, body []byte) error {
+	switch responseType {
+	case Excel:
+		return f.WriteFixture(s+".xlsx", "excel", body)
+	case CSV:
+		return f.WriteFixture(s+".csv", "csv", body)
+	default:
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, bytes.TrimSpace(body), "", "  "); err != nil {
+			return fmt.Errorf("response of '%s' is not valid JSON: %w", s, err)
+		}
+		buf.WriteByte('\n')
+
+		return f.WriteFixture(s+".json", "responses", buf.Bytes())
+	}
+}
+
+// IntegrationTestWithFixtures is an option for integration testing that changes where the fixtures are read from.
+// The root of FS, like an embed.FS with '//go:embed fixtures', or of the Dir on disk, has to hold the 'fixtures'
+// directory. Only fixtures in a Dir can be updated, FS takes precedence when both are set. The option applies to all
+// fixtures, including the init SQL script and the BigQuery data, regardless of its position among the options.
+type IntegrationTestWithFixtures struct {
+	FS  fs.FS
+	Dir string
+}
+
+// Setup replaces the fixtures of the integration test.
+func (o IntegrationTestWithFixtures) Setup(_ context.Context, it *IntegrationTest) error {
+	*it.Fixtures = o.fixtures()
+	it.Fixtures.t = it.tb
+
+	return nil
+}
+
+// TearDown does nothing, since the fixtures need no cleanup.
+func (o IntegrationTestWithFixtures) TearDown(context.Context, *IntegrationTest) error {
+	return nil
+}
+
+// fixtures returns the Fixtures that the option configures.
+func (o IntegrationTestWithFixtures) fixtures() Fixtures {
+	if o.FS != nil {
+		return Fixtures{FS: o.FS}
+	}
+
+	return Fixtures{FS: os.DirFS(o.Dir), dir: o.Dir}
+}
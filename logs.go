@@ -0,0 +1,110 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/testcontainers/testcontainers-go"
+)
+
+// containerLogTail is the number of lines of the container logs that are kept for when something fails.
+const containerLogTail = 100
+
+// containerLogs is a log consumer that keeps the last lines of the logs of a container, and streams them while
+// streaming is on.
+type containerLogs struct {
+	container string
+
+	mu     sync.Mutex
+	lines  []string
+	stream func(format string, args ...any)
+}
+
+// newContainerLogs creates a log consumer for the container with the given descriptive name.
+func newContainerLogs(container string) *containerLogs {
+	return &containerLogs{
+		container: container,
+	}
+}
+
+// config returns the log consumer configuration of a container request.
+func (l *containerLogs) config() *testcontainers.LogConsumerConfig {
+	return &testcontainers.LogConsumerConfig{
+		Consumers: []testcontainers.LogConsumer{l},
+	}
+}
+
+// Accept keeps a log line of the container, and streams it when streaming is on.
+func (l *containerLogs) Accept(log testcontainers.Log) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(log.Content), "\n"), "\n") {
+		l.lines = append(l.lines, line)
+		if l.stream != nil {
+			l.stream("[%s] %s", l.container, line)
+		}
+	}
+
+	if len(l.lines) > containerLogTail {
+		l.lines = append([]string(nil), l.lines[len(l.lines)-containerLogTail:]...)
+	}
+}
+
+// Write keeps the output of a local process, like the logs of a container.
+func (l *containerLogs) Write(p []byte) (int, error) {
+	l.Accept(testcontainers.Log{LogType: testcontainers.StderrLog, Content: p})
+
+	return len(p), nil
+}
+
+// startStreaming passes every following log line to logf.
+func (l *containerLogs) startStreaming(logf func(format string, args ...any)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stream = logf
+}
+
+// stopStreaming stops passing the log lines on, which has to happen before the test that they are logged to
+// completes. It is safe to call on nil.
+func (l *containerLogs) stopStreaming() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.stream = nil
+}
+
+// dump passes the last lines of the logs to logf, unless they were streamed already. It is safe to call on nil.
+func (l *containerLogs) dump(logf func(format string, args ...any)) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.stream != nil || len(l.lines) == 0 {
+		return
+	}
+
+	logf("last %d lines of the %s container logs:\n%s", len(l.lines), l.container, strings.Join(l.lines, "\n"))
+}
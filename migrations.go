@@ -0,0 +1,296 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// migrationFile matches the name of a migration: a version, a description and, for golang-migrate, a direction.
+	migrationFile = regexp.MustCompile(`^(\d+)_(.*?)(\.(up|down))?\.sql$`)
+
+	// gooseAnnotation matches the annotations of a goose migration, like '-- +goose Up'.
+	gooseAnnotation = regexp.MustCompile(`^--\s*\+goose\s+(.+?)\s*$`)
+)
+
+// Migration is a migration that was applied to the database.
+type Migration struct {
+	Version uint64
+	Name    string
+}
+
+// migration is a migration file, split into the statements that migrate the database up.
+type migration struct {
+	Migration
+
+	statements    []string
+	noTransaction bool
+}
+
+// readMigrations reads the up migrations in the directory, ordered by their version. Both the golang-migrate
+// convention, '1_create_users.up.sql' and '1_create_users.down.sql', and the goose convention, '1_create_users.sql'
+// with '-- +goose Up' and '-- +goose Down' annotations, are understood. Files without a version are skipped.
+func readMigrations(fsys fs.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("could not read migrations: %w", err)
+	}
+
+	var migrations []migration
+	for _, entry := range entries {
+		match := migrationFile.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil || match[4] == "down" {
+			continue
+		}
+
+		version, err := strconv.ParseUint(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version of migration '%s': %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("could not read migration '%s': %w", entry.Name(), err)
+		}
+
+		m := migration{
+			Migration: Migration{
+				Version: version,
+				Name:    entry.Name(),
+			},
+		}
+		m.statements, m.noTransaction = parseMigration(string(content))
+
+		migrations = append(migrations, m)
+	}
+
+	sort.SliceStable(migrations, func(i, j int) bool {
+		if migrations[i].Version != migrations[j].Version {
+			return migrations[i].Version < migrations[j].Version
+		}
+
+		return migrations[i].Name < migrations[j].Name
+	})
+
+	return migrations, nil
+}
+
+// parseMigration returns the statements that migrate the database up. For a goose migration, these are the ones in
+// the Up section, where a StatementBegin and StatementEnd pair marks a single statement that is not split.
+func parseMigration(content string) ([]string, bool) {
+	if !strings.Contains(content, "+goose") {
+		return splitStatements(content), false
+	}
+
+	var (
+		statements    []string
+		noTransaction bool
+		up, block     bool
+		buf           strings.Builder
+	)
+
+	flush := func() {
+		statements = append(statements, splitStatements(buf.String())...)
+		buf.Reset()
+	}
+
+	for _, line := range strings.SplitAfter(content, "\n") {
+		annotation := gooseAnnotation.FindStringSubmatch(strings.TrimSpace(line))
+		if annotation == nil {
+			if up {
+				buf.WriteString(line)
+			}
+			continue
+		}
+
+		switch strings.ToLower(annotation[1]) {
+		case "up":
+			up = true
+		case "down":
+			flush()
+			up = false
+		case "no transaction":
+			noTransaction = true
+		case "statementbegin":
+			flush()
+			block = true
+		case "statementend":
+			if block && up {
+				statement := strings.TrimSpace(buf.String())
+				if statement != "" {
+					statements = append(statements, statement)
+				}
+				buf.Reset()
+			}
+			block = false
+		}
+	}
+
+	flush()
+
+	return statements, noTransaction
+}
+
+// splitStatements splits a SQL script into its statements. Semicolons in quoted strings, quoted identifiers,
+// dollar-quoted strings and comments do not end a statement.
+func splitStatements(script string) []string {
+	var (
+		statements []string
+		start      int
+	)
+
+	add := func(statement string) {
+		if !isBlankSQL(statement) {
+			statements = append(statements, strings.TrimSpace(statement))
+		}
+	}
+
+	for i := 0; i < len(script); i++ {
+		switch {
+		case script[i] == '\'' || script[i] == '"':
+			end := strings.IndexByte(script[i+1:], script[i])
+			if end < 0 {
+				i = len(script)
+				continue
+			}
+			i += end + 1
+		case strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+				continue
+			}
+			i += end
+		case strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+				continue
+			}
+			i += end + 3
+		case script[i] == '$':
+			tag := dollarQuoteTag(script[i:])
+			if tag == "" {
+				continue
+			}
+			end := strings.Index(script[i+len(tag):], tag)
+			if end < 0 {
+				i = len(script)
+				continue
+			}
+			i += len(tag) + end + len(tag) - 1
+		case script[i] == ';':
+			add(script[start:i])
+			start = i + 1
+		}
+	}
+
+	if start < len(script) {
+		add(script[start:])
+	}
+
+	return statements
+}
+
+// dollarQuoteTag returns the tag that starts a dollar-quoted string, like '$$' or '$body$', at the start of s.
+func dollarQuoteTag(s string) string {
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 1 && c >= '0' && c <= '9':
+			continue
+		default:
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// isBlankSQL reports whether the SQL consists of nothing but whitespace and comments.
+func isBlankSQL(s string) bool {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "--") {
+			return false
+		}
+	}
+
+	return true
+}
+
+// applyMigrations applies the migrations to the database, each of them in a transaction of its own, unless it opts
+// out. It returns the migrations that were applied, up to the one that failed.
+func applyMigrations(ctx context.Context, db *sql.DB, migrations []migration) ([]Migration, error) {
+	var applied []Migration
+	for _, m := range migrations {
+		err := applyMigration(ctx, db, m)
+		if err != nil {
+			return applied, err
+		}
+
+		applied = append(applied, m.Migration)
+	}
+
+	return applied, nil
+}
+
+// applyMigration applies a single migration, naming the file and the statement that failed.
+func applyMigration(ctx context.Context, db *sql.DB, m migration) error {
+	var (
+		exec execer = db
+		tx   *sql.Tx
+		err  error
+	)
+
+	if !m.noTransaction {
+		tx, err = db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("migration '%s' failed: %w", m.Name, err)
+		}
+		defer func() {
+			_ = tx.Rollback()
+		}()
+
+		exec = tx
+	}
+
+	for _, statement := range m.statements {
+		_, err = exec.ExecContext(ctx, statement)
+		if err != nil {
+			return fmt.Errorf("migration '%s' failed at statement:\n%s\n%w", m.Name, statement, err)
+		}
+	}
+
+	if tx != nil {
+		err = tx.Commit()
+		if err != nil {
+			return fmt.Errorf("migration '%s' failed: %w", m.Name, err)
+		}
+	}
+
+	return nil
+}
@@ -15,13 +15,25 @@
 package echoprobe
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"path"
+	"regexp"
 	"strings"
+	"sync"
+	"testing"
 
 	"github.com/h2non/gock"
 )
 
+// defaultTransportMu guards the interception of http.DefaultTransport, which is shared by the whole process.
+var defaultTransportMu sync.Mutex
+
 // MockCall represents a mocked API call used in tests
 type MockCall struct {
 	Function func(config *MockConfig)
@@ -36,10 +48,27 @@ type MockConfig struct {
 	Response   string
 }
 
-// Mock is the struct that gives access to all the mocks
+// Mock is the struct that gives access to all the mocks. It is an http.RoundTripper that answers the requests of the
+// intercepted clients with the registered mocks. Every integration test has a Mock of its own, so tests that run in
+// parallel do not affect each other, as long as each of them intercepts its own http.Client.
 type Mock struct {
 	baseURL    string
 	httpClient *http.Client
+	fixtures   *Fixtures
+	t          testing.TB
+
+	mu        sync.Mutex
+	responses []*mockResponse
+	debug     bool
+	restores  []func()
+}
+
+// mockResponse is a registered mock, paired with the request it answers.
+type mockResponse struct {
+	method     string
+	url        *url.URL
+	statusCode int
+	body       []byte
 }
 
 // NewMock creates a new Mock
@@ -49,70 +78,287 @@ func NewMock(baseURL string) *Mock {
 	}
 }
 
-// TearDown removes all the registered mocks
+// TearDown removes all the registered mocks and stops intercepting the http clients.
 func (m *Mock) TearDown() {
-	gock.Off()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.restores) - 1; i >= 0; i-- {
+		m.restores[i]()
+	}
+
+	m.restores = nil
+	m.responses = nil
 }
 
 // Debug is used to print the request URL and the mock returned for that particular request
 func (m *Mock) Debug() {
-	gock.Observe(func(req *http.Request, mock gock.Mock) {
-		debug := fmt.Sprintf(
-			"\n-- MOCK START\n"+
-				"%s - %d \n"+
-				"%s \n"+
-				"-- MOCK END\n",
-			req.URL, mock.Response().StatusCode, string(mock.Response().BodyBuffer),
-		)
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-		fmt.Println(debug)
-	})
+	m.debug = true
 }
 
+// SetHttpClient makes the mocks answer the requests of the given http.Client. Intercepting a client of its own keeps
+// the test independent of the other tests, which is required when they run in parallel.
 func (m *Mock) SetHttpClient(httpClient *http.Client) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	transport := httpClient.Transport
+	httpClient.Transport = m
+
 	m.httpClient = httpClient
+	m.restores = append(m.restores, func() {
+		httpClient.Transport = transport
+	})
+}
+
+// Client returns a new http.Client whose requests are answered by the mocks. Once a client is asked for, the mocks no
+// longer intercept the default transport.
+func (m *Mock) Client() *http.Client {
+	client := &http.Client{
+		Transport: m,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.httpClient == nil {
+		m.httpClient = client
+	}
+
+	return client
+}
+
+// RoundTrip answers the request with the first registered mock that matches it. Every mock answers a single request.
+func (m *Mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		_ = req.Body.Close()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, mock := range m.responses {
+		match, err := mock.match(req)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+
+		m.responses = append(m.responses[:i], m.responses[i+1:]...)
+
+		if m.debug {
+			m.log(fmt.Sprintf(
+				"\n-- MOCK START\n"+
+					"%s - %d \n"+
+					"%s \n"+
+					"-- MOCK END\n",
+				req.URL, mock.statusCode, string(mock.body),
+			))
+		}
+
+		return mock.response(req), nil
+	}
+
+	return nil, fmt.Errorf("echoprobe: no mock matches the request %s %s", req.Method, req.URL)
 }
 
+// log prints the message to the test, or to the standard output when the Mock does not belong to one.
+func (m *Mock) log(msg string) {
+	if m.t != nil {
+		m.t.Log(msg)
+		return
+	}
+
+	fmt.Println(msg)
+}
+
+// SetJSON sets the body of a gock response to the response fixture of the config.
+//
+// Deprecated: the mocks no longer use gock, so the gock response is not returned to any request. Register the mocks
+// through the Mocks of a test case or MockRequest, which read the response fixture themselves. SetJSON will be removed
+// in the next major version.
 func (m *Mock) SetJSON(response *gock.Response, config *MockConfig) {
-	var f Fixtures
-	if strings.TrimSpace(config.Response) != "" {
-		response.JSON(
-			f.ReadFixture(
-				fmt.Sprintf("%s.json", config.Response),
-				"mocks",
-			),
-		)
+	if strings.TrimSpace(config.Response) == "" {
+		return
+	}
+
+	f := m.fixtures
+	if f == nil {
+		f = &Fixtures{}
+	}
+
+	body, err := f.ReadFixtureE(
+		fmt.Sprintf("%s.json", config.Response),
+		"mocks",
+	)
+	if err != nil {
+		m.fail("%v", err)
+		return
 	}
+
+	response.JSON(body)
 }
 
+// MockRequest registers a mock for the request that the config describes. A mock response that cannot be read is
+// reported to the test that owns the Mock.
 func (m *Mock) MockRequest(config *MockConfig) {
+	err := m.mockRequest(config)
+	if err != nil {
+		m.fail("%v", err)
+	}
+}
+
+// mockRequest registers a mock like MockRequest, but returns the errors instead of reporting them.
+func (m *Mock) mockRequest(config *MockConfig) error {
 	if config.StatusCode == 0 {
 		config.StatusCode = http.StatusOK
 	}
 
-	request := gock.New(m.baseURL)
-	if m.httpClient != nil {
-		gock.InterceptClient(m.httpClient)
+	method := config.Method
+	switch method {
+	case http.MethodGet, http.MethodDelete, http.MethodPost, http.MethodPut, http.MethodPatch:
+	default:
+		method = http.MethodGet
+	}
+
+	mockURL, err := url.Parse(normalizeMockURL(m.baseURL))
+	if err != nil {
+		return fmt.Errorf("could not parse mock base URL '%s': %w", m.baseURL, err)
 	}
+	mockURL.Path = path.Join(mockURL.Path, config.UrlPath)
 
-	switch config.Method {
-	case http.MethodGet:
-		request.Get(config.UrlPath)
-	case http.MethodDelete:
-		request.Delete(config.UrlPath)
-	case http.MethodPost:
-		request.Post(config.UrlPath)
-	case http.MethodPut:
-		request.Put(config.UrlPath)
-	case http.MethodPatch:
-		request.Patch(config.UrlPath)
-	default:
-		request.Get(config.UrlPath)
+	mock := &mockResponse{
+		method:     method,
+		url:        mockURL,
+		statusCode: config.StatusCode,
 	}
 
-	response := request.Reply(
-		config.StatusCode,
-	)
+	if strings.TrimSpace(config.Response) != "" {
+		f := m.fixtures
+		if f == nil {
+			f = &Fixtures{}
+		}
+
+		body, err := f.ReadFixtureE(
+			fmt.Sprintf("%s.json", config.Response),
+			"mocks",
+		)
+		if err != nil {
+			return err
+		}
+
+		mock.body = []byte(body)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// Without a client of its own, the mock intercepts the default transport, like the http.DefaultClient uses.
+	if m.httpClient == nil && len(m.restores) == 0 {
+		err := m.interceptDefaultTransport()
+		if err != nil {
+			return err
+		}
+	}
+
+	m.responses = append(m.responses, mock)
+
+	return nil
+}
+
+// interceptDefaultTransport makes the mocks answer the requests of http.DefaultTransport. The default transport is
+// shared by the whole process, so only one Mock can intercept it at a time. When another one does already, like the
+// Mock of a test that runs in parallel, an error is returned instead of taking its requests over.
+func (m *Mock) interceptDefaultTransport() error {
+	defaultTransportMu.Lock()
+	defer defaultTransportMu.Unlock()
+
+	if other, ok := http.DefaultTransport.(*Mock); ok && other != m {
+		return errors.New(
+			"http.DefaultTransport is intercepted by the mocks of another test, tests that run in parallel have to " +
+				"intercept a client of their own with SetHttpClient or Client",
+		)
+	}
+
+	transport := http.DefaultTransport
+	http.DefaultTransport = m
+
+	m.restores = append(m.restores, func() {
+		defaultTransportMu.Lock()
+		defer defaultTransportMu.Unlock()
+
+		if http.DefaultTransport == m {
+			http.DefaultTransport = transport
+		}
+	})
+
+	return nil
+}
+
+// fail reports an error to the test, or exits like before when the Mock does not belong to one.
+func (m *Mock) fail(format string, args ...any) {
+	if m.t != nil {
+		m.t.Errorf(format, args...)
+		return
+	}
+
+	log.Fatalf(format, args...)
+}
+
+// match reports whether the mock answers the request. The host and the path of the mock are regular expressions.
+func (r *mockResponse) match(req *http.Request) (bool, error) {
+	if req.Method != r.method {
+		return false, nil
+	}
+
+	if r.url.Scheme != "" && req.URL.Scheme != "" && r.url.Scheme != req.URL.Scheme {
+		return false, nil
+	}
+
+	if !strings.EqualFold(r.url.Host, req.URL.Host) {
+		match, err := regexp.MatchString(r.url.Host, req.URL.Host)
+		if err != nil || !match {
+			return false, err
+		}
+	}
+
+	if req.URL.Path != r.url.Path {
+		return regexp.MatchString(r.url.Path, req.URL.Path)
+	}
+
+	return true, nil
+}
+
+// response builds the http.Response of the mock.
+func (r *mockResponse) response(req *http.Request) *http.Response {
+	header := http.Header{}
+	if r.body != nil {
+		header.Set("Content-Type", "application/json")
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", r.statusCode, http.StatusText(r.statusCode)),
+		StatusCode:    r.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(r.body)),
+		ContentLength: int64(len(r.body)),
+		Request:       req,
+	}
+}
+
+// normalizeMockURL adds the http scheme to base URLs without one, like '/v1' or 'weather.test'.
+func normalizeMockURL(baseURL string) string {
+	if !strings.HasPrefix(baseURL, "http://") && !strings.HasPrefix(baseURL, "https://") {
+		return "http://" + baseURL
+	}
 
-	m.SetJSON(response, config)
+	return baseURL
 }
@@ -0,0 +1,191 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/docker/go-connections/nat"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+const (
+	mysqlImage    = "mysql:8.4"
+	mysqlName     = "test"
+	mysqlUsername = "root"
+	mysqlPassword = "password"
+	mysqlPort     = "3306/tcp"
+
+	// mysqlStartupTimeout is how long the default wait strategy waits for the server, which initializes its data
+	// directory on the first start.
+	mysqlStartupTimeout = 2 * time.Minute
+)
+
+// MySQLContainer holds all the necessary information for a MySQL or MariaDB database test container.
+type MySQLContainer struct {
+	testcontainers.Container
+
+	DBHost     string
+	DBPort     int
+	DBName     string
+	DBUsername string
+	DBPassword string
+
+	// logs holds the last lines of the logs of the container.
+	logs *containerLogs
+}
+
+// setupMySQLDB sets up a MySQL database test container, configured by the option. The container is terminated when
+// any step of the setup fails, so that a partial setup does not leave it running. The logs of the container are
+// passed to the log consumer.
+func setupMySQLDB(ctx context.Context, o IntegrationTestWithMySQL, logs *containerLogs) (_ *MySQLContainer, err error) {
+	image := o.Image
+	if strings.TrimSpace(image) == "" {
+		image = mysqlImage
+	}
+
+	env := make(map[string]string, len(o.Env)+2)
+	for key, value := range o.Env {
+		env[key] = value
+	}
+
+	// The credentials cannot be changed, since they are needed to connect to the database. MariaDB understands the
+	// MYSQL_ variables as well.
+	env["MYSQL_ROOT_PASSWORD"] = mysqlPassword
+	env["MYSQL_DATABASE"] = mysqlName
+
+	waitingFor := o.WaitingFor
+	if waitingFor == nil {
+		waitingFor = wait.ForSQL(mysqlPort, "mysql", mysqlURL).WithStartupTimeout(mysqlStartupTimeout)
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:          image,
+			Env:            env,
+			ExposedPorts:   []string{mysqlPort},
+			WaitingFor:     waitingFor,
+			LogConsumerCfg: logs.config(),
+		},
+		Started: true,
+	})
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, testcontainers.TerminateContainer(container))
+		}
+	}()
+	if err != nil {
+		return nil, err
+	}
+
+	hostIP, err := container.Host(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	mappedPort, err := container.MappedPort(ctx, mysqlPort)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MySQLContainer{
+		Container:  container,
+		DBHost:     hostIP,
+		DBPort:     mappedPort.Int(),
+		DBName:     mysqlName,
+		DBUsername: mysqlUsername,
+		DBPassword: mysqlPassword,
+		logs:       logs,
+	}, nil
+}
+
+// mysqlURL returns the MySQL database URL.
+func mysqlURL(host string, port nat.Port) string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%s)/%s", mysqlUsername, mysqlPassword, host, port.Port(), mysqlName)
+}
+
+// dsn returns the data source name of the database in the container. Times are parsed into time.Time, as gorm
+// expects.
+func (c *MySQLContainer) dsn() string {
+	return fmt.Sprintf(
+		"%s:%s@tcp(%s:%d)/%s?parseTime=true", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName,
+	)
+}
+
+// initDatabase runs the init scripts of the option, in the order they are given.
+func (c *MySQLContainer) initDatabase(ctx context.Context, fixtures *Fixtures, scripts []string) error {
+	if len(scripts) == 0 {
+		return nil
+	}
+
+	// A script is sent as a whole, so it may hold several statements.
+	db, err := sql.Open("mysql", c.dsn()+"&multiStatements=true")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	for _, filename := range scripts {
+		script, err := fixtures.readFile(path.Join("fixtures", filename))
+		if err != nil {
+			return err
+		}
+
+		_, err = db.ExecContext(ctx, string(script))
+		if err != nil {
+			return fmt.Errorf("init script '%s' failed: %w", filename, err)
+		}
+	}
+
+	return nil
+}
+
+// connectMySQL opens gorm on the MySQL database of the integration test.
+func (it *IntegrationTest) connectMySQL(o IntegrationTestWithMySQL) error {
+	config := o.Config
+	if config == nil {
+		config = &gorm.Config{}
+	}
+
+	db, err := gorm.Open(mysql.Open(it.MySQLContainer.dsn()), config)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	err = it.queries.install(db)
+	if err != nil {
+		return fmt.Errorf("database connection error: %w", err)
+	}
+
+	it.Db = db
+	it.db.sqlDB = sqlDB
+
+	return nil
+}
@@ -15,26 +15,50 @@
 package echoprobe
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
+	"io"
+	"path"
+	"regexp"
 	"strings"
 
 	"github.com/docker/go-connections/nat"
+	"github.com/lib/pq"
 	"github.com/testcontainers/testcontainers-go"
+	tcexec "github.com/testcontainers/testcontainers-go/exec"
 	"github.com/testcontainers/testcontainers-go/wait"
-
-	_ "github.com/lib/pq"
 )
 
 const (
+	dbImage    = "postgres:latest"
 	dbName     = "postgres"
 	dbUsername = "postgres"
 	dbPassword = "password"
 	dbPort     = "5432/tcp"
+
+	// dbTmpfsPath is where the in-memory filesystem for the data directory is mounted, when it is requested. The data
+	// directory is a subdirectory, so that the entrypoint of the image creates it with the right owner.
+	dbTmpfsPath = "/var/lib/postgresql/tmpfs"
+
+	// dbSnapshotName is the database holding the state after the init script, when the cases are isolated.
+	dbSnapshotName = "echoprobe_snapshot"
+
+	// dbTemplateName is the database of a shared or reused postgres container that holds the state after the init
+	// script. The integration tests get a copy of it.
+	dbTemplateName = "echoprobe_template"
+
+	// dbMaintenanceName is the database used to create and drop the other databases. Since every database is created
+	// with an explicit template, nothing depends on it not being in use.
+	dbMaintenanceName = "template1"
 )
 
-// PostgresDBContainer holds all the necessary information for postgres database test container.
+// PostgresDBContainer holds all the necessary information for postgres database test container. With the local backend,
+// postgres runs as a local process and the Container is nil.
 type PostgresDBContainer struct {
 	testcontainers.Container
 
@@ -43,36 +67,86 @@ type PostgresDBContainer struct {
 	DBName     string
 	DBUsername string
 	DBPassword string
+
+	// Migrations are the migrations that were applied to the database, in the order they ran.
+	Migrations []Migration
+
+	// name is the name of the container when it is reused across test runs, which keeps it from being terminated.
+	name string
+
+	// logs holds the last lines of the logs of the container.
+	logs *containerLogs
+
+	// local is the postgres process of the local backend.
+	local *localPostgres
 }
 
-// setupPostgresDB sets up a postgres database test container.
-func setupPostgresDB(ctx context.Context, initSQLScript ...string) (*PostgresDBContainer, error) {
+// execer is implemented by *sql.DB, *sql.Conn and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// setupPostgresDB sets up a postgres database test container, configured by the option. The container is terminated
+// when any step of the setup fails, so that a partial setup does not leave it running. With a name, the container is
+// reused: a running container with that name is reattached to, and it is never terminated. The logs of the container
+// are passed to the log consumer.
+func setupPostgresDB(
+	ctx context.Context, o IntegrationTestWithPostgres, name string, logs *containerLogs,
+) (_ *PostgresDBContainer, err error) {
+	image := o.Image
+	if strings.TrimSpace(image) == "" {
+		image = dbImage
+	}
+
+	env := make(map[string]string, len(o.Env)+3)
+	for key, value := range o.Env {
+		env[key] = value
+	}
+
+	// The credentials cannot be changed, since they are needed to connect to the database.
+	env["POSTGRES_USER"] = dbUsername
+	env["POSTGRES_PASSWORD"] = dbPassword
+
 	req := testcontainers.ContainerRequest{
-		Image: "postgres:latest",
-		Env: map[string]string{
-			"POSTGRES_USER":     dbUsername,
-			"POSTGRES_PASSWORD": dbPassword,
-		},
-		ExposedPorts: []string{dbPort},
-		WaitingFor:   wait.ForSQL(dbPort, "postgres", dbURL),
+		Image:          image,
+		Env:            env,
+		ExposedPorts:   []string{dbPort},
+		WaitingFor:     wait.ForSQL(dbPort, "postgres", dbURL),
+		LogConsumerCfg: logs.config(),
+	}
+
+	if len(o.Settings) > 0 {
+		req.Cmd = []string{"postgres"}
+		for _, setting := range o.Settings {
+			req.Cmd = append(req.Cmd, "-c", setting)
+		}
+	}
+
+	if o.Tmpfs {
+		req.Tmpfs = map[string]string{dbTmpfsPath: "rw"}
+		env["PGDATA"] = dbTmpfsPath + "/data"
+	}
+
+	if name != "" {
+		reuseRequest(&req, name)
 	}
 
 	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
 		ContainerRequest: req,
 		Started:          true,
+		Reuse:            name != "",
 	})
+	defer func() {
+		// A reused container may be in use by another test process.
+		if err != nil && name == "" {
+			err = errors.Join(err, testcontainers.TerminateContainer(container))
+		}
+	}()
 	if err != nil {
 		return nil, err
 	}
 
-	// If init script path is provided, initialize the database using the script.
-	if strings.TrimSpace(initSQLScript[0]) != "" {
-		err = initDB(container, initSQLScript[0])
-		if err != nil {
-			return nil, err
-		}
-	}
-
 	hostIP, err := container.Host(ctx)
 	if err != nil {
 		return nil, err
@@ -90,9 +164,25 @@ func setupPostgresDB(ctx context.Context, initSQLScript ...string) (*PostgresDBC
 		DBName:     dbName,
 		DBUsername: dbUsername,
 		DBPassword: dbPassword,
+		name:       name,
+		logs:       logs,
 	}, nil
 }
 
+// reused reports whether the container is reused across test runs.
+func (c *PostgresDBContainer) reused() bool {
+	return c.name != ""
+}
+
+// terminate terminates the container, or stops the local postgres process.
+func (c *PostgresDBContainer) terminate(ctx context.Context) error {
+	if c.local != nil {
+		return c.local.stop()
+	}
+
+	return c.Terminate(ctx)
+}
+
 // dbURL returns the postgres database URL.
 func dbURL(host string, port nat.Port) string {
 	return fmt.Sprintf(
@@ -100,44 +190,246 @@ func dbURL(host string, port nat.Port) string {
 	)
 }
 
-// initDB initializes the database using the provided script.
-func initDB(container testcontainers.Container, filename string) error {
-	executionPath, err := testpath()
+// dsn returns the URL of the given database in the container.
+func (c *PostgresDBContainer) dsn(database string) string {
+	return fmt.Sprintf(
+		"postgres://%s:%s@%s:%d/%s?sslmode=disable", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, database,
+	)
+}
+
+// initDatabase prepares the database as requested by the option, creating the extensions first and applying the
+// migrations next, so that the init script can use both. The output of the init script is passed to logf.
+func (c *PostgresDBContainer) initDatabase(
+	ctx context.Context, fixtures *Fixtures, o IntegrationTestWithPostgres, database string,
+	logf func(format string, args ...any),
+) error {
+	if len(o.Extensions) > 0 {
+		err := c.createExtensions(ctx, database, o.Extensions)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.MigrationsFS != nil || strings.TrimSpace(o.MigrationsDir) != "" {
+		err := c.migrate(ctx, fixtures, o, database)
+		if err != nil {
+			return err
+		}
+	}
+
+	// If init script path is provided, initialize the database using the script.
+	if strings.TrimSpace(o.InitSQLScript) != "" {
+		return c.initDB(ctx, fixtures, o.InitSQLScript, database, o.InitSQLTransaction, logf)
+	}
+
+	return nil
+}
+
+// createExtensions creates the extensions in the database.
+func (c *PostgresDBContainer) createExtensions(ctx context.Context, database string, extensions []string) error {
+	db, err := sql.Open("postgres", c.dsn(database))
 	if err != nil {
 		return err
 	}
+	defer db.Close()
 
-	containerPath := fmt.Sprintf("/%s", filename)
+	for _, extension := range extensions {
+		_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE EXTENSION IF NOT EXISTS %s", pq.QuoteIdentifier(extension)))
+		if err != nil {
+			return fmt.Errorf("could not create extension '%s': %w", extension, err)
+		}
+	}
 
-	// Copy the script from the host path to the container
-	err = container.CopyFileToContainer(
-		context.Background(),
-		fmt.Sprintf(
-			"%s/fixtures/%s", executionPath, filename,
-		),
-		containerPath,
-		0544,
-	)
+	return nil
+}
+
+// migrate applies the migrations of the option to the database.
+func (c *PostgresDBContainer) migrate(
+	ctx context.Context, fixtures *Fixtures, o IntegrationTestWithPostgres, database string,
+) error {
+	migrations, err := o.readMigrations(fixtures)
 	if err != nil {
 		return err
 	}
 
-	// Execute the script
-	stdout, stderr, err := container.Exec(context.Background(), []string{
-		"bash",
-		"-c",
-		fmt.Sprintf(
-			"export PGPASSWORD=%s && psql -U %s -d %s -f %s",
-			dbPassword, dbUsername, dbName, containerPath,
-		),
+	db, err := sql.Open("postgres", c.dsn(database))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	c.Migrations, err = applyMigrations(ctx, db, migrations)
+
+	return err
+}
+
+// readMigrations reads the migrations of the option, if it has any. Without MigrationsFS, the MigrationsDir is
+// relative to the 'fixtures' directory.
+func (o IntegrationTestWithPostgres) readMigrations(fixtures *Fixtures) ([]migration, error) {
+	if o.MigrationsFS == nil && strings.TrimSpace(o.MigrationsDir) == "" {
+		return nil, nil
+	}
+
+	fsys, dir := o.MigrationsFS, path.Clean(o.MigrationsDir)
+	if fsys == nil {
+		root, name, _, err := fixtures.resolve(path.Join("fixtures", dir))
+		if err != nil {
+			return nil, fmt.Errorf("could not read migrations: %w", err)
+		}
+
+		fsys, dir = root, name
+	}
+
+	return readMigrations(fsys, dir)
+}
+
+// createDatabase creates a new database as a copy of the template database. The copies are made one at a time, even
+// by different test processes, since postgres refuses to copy a template that is being copied already.
+func (c *PostgresDBContainer) createDatabase(ctx context.Context, name, template string) error {
+	return c.withLock(ctx, cloneLockID, func(exec execer) error {
+		_, err := exec.ExecContext(ctx, fmt.Sprintf(
+			"CREATE DATABASE %s TEMPLATE %s", pq.QuoteIdentifier(name), pq.QuoteIdentifier(template),
+		))
+
+		return err
 	})
+}
 
-	log.Println("[stdout] container exec: ", stdout)
-	log.Println("[stderr] container exec: ", stderr)
+// dropDatabase drops the database, terminating the connections that are still open to it.
+func (c *PostgresDBContainer) dropDatabase(ctx context.Context, name string) error {
+	return c.execMaintenance(ctx, fmt.Sprintf(
+		"DROP DATABASE IF EXISTS %s WITH (FORCE)", pq.QuoteIdentifier(name),
+	))
+}
 
+// restoreDatabase replaces the database with a fresh copy of the template database.
+func (c *PostgresDBContainer) restoreDatabase(ctx context.Context, name, template string) error {
+	err := c.dropDatabase(ctx, name)
 	if err != nil {
 		return err
 	}
 
+	return c.createDatabase(ctx, name, template)
+}
+
+// execMaintenance executes a statement over a connection to the maintenance database.
+func (c *PostgresDBContainer) execMaintenance(ctx context.Context, query string) error {
+	db, err := sql.Open("postgres", c.dsn(dbMaintenanceName))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	_, err = db.ExecContext(ctx, query)
+
 	return err
 }
+
+// withLock runs the function over a connection to the maintenance database, while holding the advisory lock.
+func (c *PostgresDBContainer) withLock(ctx context.Context, id int64, fn func(exec execer) error) error {
+	db, err := sql.Open("postgres", c.dsn(dbMaintenanceName))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_, _ = conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", id)
+	}()
+
+	return fn(conn)
+}
+
+// randomDatabaseName returns a database name that is unique, even among test processes sharing a container.
+func randomDatabaseName() string {
+	buf := make([]byte, 6)
+	_, _ = rand.Read(buf)
+
+	return "echoprobe_" + hex.EncodeToString(buf)
+}
+
+// psqlError matches the error that psql reports for a statement of a script, like
+// 'psql:/init-db.sql:3: ERROR:  relation "users" does not exist'.
+var psqlError = regexp.MustCompile(`(?m)^psql:[^:]*:(\d+): (ERROR: .*)$`)
+
+// initDB initializes the database using the provided script. The script stops at the first error, which is
+// reported with the line it occurred on. With transaction set, the whole script runs in a single transaction, so a
+// failing script leaves nothing behind. The output of psql is passed to logf.
+func (c *PostgresDBContainer) initDB(
+	ctx context.Context, fixtures *Fixtures, filename, database string, transaction bool,
+	logf func(format string, args ...any),
+) error {
+	script, err := fixtures.readFile(path.Join("fixtures", filename))
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-X", "-v", "ON_ERROR_STOP=1", "-U", dbUsername, "-d", database}
+	if transaction {
+		args = append(args, "--single-transaction")
+	}
+
+	// Execute the script
+	exitCode, output, err := c.psql(ctx, filename, script, args)
+	if err != nil {
+		return fmt.Errorf("could not run init script '%s': %w", filename, err)
+	}
+
+	if len(bytes.TrimSpace(output)) > 0 {
+		logf("init script '%s':\n%s", filename, output)
+	}
+
+	if exitCode == 0 {
+		return nil
+	}
+
+	if match := psqlError.FindSubmatch(output); match != nil {
+		return fmt.Errorf("init script '%s' failed at line %s: %s", filename, match[1], match[2])
+	}
+
+	return fmt.Errorf(
+		"init script '%s' failed with exit code %d: %s", filename, exitCode, bytes.TrimSpace(output),
+	)
+}
+
+// psql runs the script with psql, in the container or with the local backend, and returns its exit code and output.
+func (c *PostgresDBContainer) psql(
+	ctx context.Context, filename string, script []byte, args []string,
+) (int, []byte, error) {
+	if c.local != nil {
+		return c.local.psql(ctx, c.DBPort, script, args)
+	}
+
+	containerPath := fmt.Sprintf("/%s", filename)
+
+	// Copy the script from the fixtures to the container
+	err := c.CopyToContainer(ctx, script, containerPath, 0544)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	exitCode, reader, err := c.Exec(
+		ctx, append([]string{"psql", "-f", containerPath}, args...),
+		tcexec.Multiplexed(), tcexec.WithEnv([]string{"PGPASSWORD=" + dbPassword}),
+	)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		return 0, nil, fmt.Errorf("could not read the output: %w", err)
+	}
+
+	return exitCode, output, nil
+}
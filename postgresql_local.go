@@ -0,0 +1,345 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PostgresBackend selects how IntegrationTestWithPostgres runs postgres.
+type PostgresBackend string
+
+const (
+	// PostgresBackendAuto runs postgres in a container when the Docker daemon can be reached, and as a local process
+	// when it cannot and the postgres binaries are installed. An option with an Image or Tmpfs always needs Docker,
+	// since the local backend cannot honor them.
+	PostgresBackendAuto PostgresBackend = ""
+
+	// PostgresBackendDocker runs postgres in a container.
+	PostgresBackendDocker PostgresBackend = "docker"
+
+	// PostgresBackendLocal runs postgres as a local process, from the binaries that are installed on the machine.
+	PostgresBackendLocal PostgresBackend = "local"
+)
+
+const (
+	// localStartupTimeout is how long a local postgres gets to accept connections.
+	localStartupTimeout = 30 * time.Second
+
+	// localStopTimeout is how long a local postgres gets to shut down, before it is killed.
+	localStopTimeout = 10 * time.Second
+)
+
+// ErrNoLocalPostgres is returned when the local backend is requested, but postgres cannot run locally, since its
+// binaries cannot be found or the tests run as root, which postgres refuses.
+var ErrNoLocalPostgres = errors.New("postgres cannot run locally")
+
+var (
+	errNoPostgresBin = errors.New(
+		"the postgres binaries are not found, install postgres or set LocalBinDir to the directory that holds initdb",
+	)
+	errPostgresAsRoot = errors.New("postgres refuses to run as root, run the tests as another user")
+)
+
+// localPostgres is a postgres server that runs as a local process, with its data in a temporary directory.
+type localPostgres struct {
+	binDir string
+	dir    string
+	cmd    *exec.Cmd
+	exited chan struct{}
+}
+
+// backend returns the backend that runs postgres for the option. The automatic selection falls back to the local
+// binaries only when the Docker daemon cannot be reached and the option can run locally, otherwise it keeps to Docker,
+// which fails with the reason.
+func (o IntegrationTestWithPostgres) backend(ctx context.Context, probe *dockerProbe) (PostgresBackend, error) {
+	switch o.Backend {
+	case PostgresBackendDocker, PostgresBackendLocal:
+		return o.Backend, nil
+	case PostgresBackendAuto:
+		if probe.reachable(ctx) == nil {
+			return PostgresBackendDocker, nil
+		}
+
+		if o.runsLocally() {
+			return PostgresBackendLocal, nil
+		}
+
+		return PostgresBackendDocker, nil
+	default:
+		return "", fmt.Errorf("unknown postgres backend '%s'", o.Backend)
+	}
+}
+
+// runsLocally reports whether the automatic selection may fall back to the local binaries. An Image, like PostGIS or
+// a pinned version, and Tmpfs are ignored by the local backend, so an option with either of them keeps to Docker,
+// instead of failing later on a missing extension or version.
+func (o IntegrationTestWithPostgres) runsLocally() bool {
+	if o.Image != "" || o.Tmpfs {
+		return false
+	}
+
+	_, err := findLocalPostgres(o.LocalBinDir)
+
+	return err == nil
+}
+
+// startPostgresDB starts a postgres database with the backend, in a container or as a local process.
+func startPostgresDB(
+	ctx context.Context, o IntegrationTestWithPostgres, backend PostgresBackend, logs *containerLogs,
+) (*PostgresDBContainer, error) {
+	if backend == PostgresBackendLocal {
+		return setupLocalPostgresDB(ctx, o, logs)
+	}
+
+	return setupPostgresDB(ctx, o, "", logs)
+}
+
+// setupLocalPostgresDB initializes a data directory in a temporary directory and starts postgres on it, on a random
+// port of the loopback interface. The server settings and the environment variables of the option are passed to it,
+// like to the container. The output of the server is passed to the log consumer. The Container of the result is nil.
+func setupLocalPostgresDB(
+	ctx context.Context, o IntegrationTestWithPostgres, logs *containerLogs,
+) (_ *PostgresDBContainer, err error) {
+	binDir, err := findLocalPostgres(o.LocalBinDir)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp("", "echoprobe-postgres-")
+	if err != nil {
+		return nil, err
+	}
+
+	local := &localPostgres{
+		binDir: binDir,
+		dir:    dir,
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, local.stop())
+		}
+	}()
+
+	dataDir := filepath.Join(dir, "data")
+
+	// The server only listens on the loopback interface, so it trusts the connections without a password.
+	initdb := exec.CommandContext(
+		ctx, filepath.Join(binDir, "initdb"),
+		"-D", dataDir, "-U", dbUsername, "-A", "trust", "-E", "UTF8", "--no-locale", "--no-sync",
+	)
+	initdb.Env = localEnv(o.Env)
+
+	output, err := initdb.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("initdb failed: %w: %s", err, bytes.TrimSpace(output))
+	}
+
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"-D", dataDir, "-h", "127.0.0.1", "-p", strconv.Itoa(port), "-k", ""}
+	for _, setting := range o.Settings {
+		args = append(args, "-c", setting)
+	}
+
+	local.cmd = exec.Command(filepath.Join(binDir, "postgres"), args...)
+	local.cmd.Env = localEnv(o.Env)
+	local.cmd.Stdout = logs
+	local.cmd.Stderr = logs
+
+	err = local.cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("could not start postgres: %w", err)
+	}
+
+	local.exited = make(chan struct{})
+	go func() {
+		_ = local.cmd.Wait()
+		close(local.exited)
+	}()
+
+	c := &PostgresDBContainer{
+		DBHost:     "127.0.0.1",
+		DBPort:     port,
+		DBName:     dbName,
+		DBUsername: dbUsername,
+		DBPassword: dbPassword,
+		logs:       logs,
+		local:      local,
+	}
+
+	err = local.waitReady(ctx, c.dsn(dbName))
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// waitReady waits until postgres accepts connections.
+func (p *localPostgres) waitReady(ctx context.Context, dsn string) error {
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, localStartupTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		err = db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+
+		select {
+		case <-p.exited:
+			return fmt.Errorf("postgres exited: %s", p.cmd.ProcessState)
+		case <-ctx.Done():
+			return fmt.Errorf("postgres is not ready: %w", errors.Join(ctx.Err(), err))
+		case <-ticker.C:
+		}
+	}
+}
+
+// psql runs the script with the local psql, passing it on the standard input.
+func (p *localPostgres) psql(ctx context.Context, port int, script []byte, args []string) (int, []byte, error) {
+	args = append([]string{"-h", "127.0.0.1", "-p", strconv.Itoa(port), "-f", "-"}, args...)
+
+	cmd := exec.CommandContext(ctx, filepath.Join(p.binDir, "psql"), args...)
+	cmd.Stdin = bytes.NewReader(script)
+
+	output, err := cmd.CombinedOutput()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode(), output, nil
+	}
+
+	return 0, output, err
+}
+
+// stop shuts postgres down and removes its temporary directory. Postgres is asked to shut down fast, which
+// disconnects the clients, and is killed when it does not.
+func (p *localPostgres) stop() error {
+	if p.cmd != nil && p.cmd.Process != nil {
+		err := p.cmd.Process.Signal(os.Interrupt)
+		if err != nil {
+			_ = p.cmd.Process.Kill()
+		}
+
+		select {
+		case <-p.exited:
+		case <-time.After(localStopTimeout):
+			_ = p.cmd.Process.Kill()
+			<-p.exited
+		}
+	}
+
+	return os.RemoveAll(p.dir)
+}
+
+// findLocalPostgres returns the directory of the postgres binaries, when postgres can run locally.
+func findLocalPostgres(dir string) (string, error) {
+	if os.Geteuid() == 0 {
+		return "", fmt.Errorf("%w: %w", ErrNoLocalPostgres, errPostgresAsRoot)
+	}
+
+	return findPostgresBin(dir)
+}
+
+// findPostgresBin returns the directory of the postgres binaries: the given one, the one reported by pg_config, the
+// one of initdb on the PATH, or the one of the newest version in the directory that Debian and Ubuntu install them in.
+func findPostgresBin(dir string) (string, error) {
+	if strings.TrimSpace(dir) != "" {
+		if _, err := exec.LookPath(filepath.Join(dir, "initdb")); err != nil {
+			return "", fmt.Errorf("%w: %w: %w", ErrNoLocalPostgres, errNoPostgresBin, err)
+		}
+
+		return dir, nil
+	}
+
+	if pgConfig, err := exec.LookPath("pg_config"); err == nil {
+		output, err := exec.Command(pgConfig, "--bindir").Output()
+		if err == nil {
+			dir = strings.TrimSpace(string(output))
+			if _, err := exec.LookPath(filepath.Join(dir, "initdb")); err == nil {
+				return dir, nil
+			}
+		}
+	}
+
+	if initdb, err := exec.LookPath("initdb"); err == nil {
+		return filepath.Dir(initdb), nil
+	}
+
+	matches, _ := filepath.Glob("/usr/lib/postgresql/*/bin/initdb")
+	sort.Slice(matches, func(i, j int) bool {
+		return postgresVersion(matches[i]) > postgresVersion(matches[j])
+	})
+
+	if len(matches) > 0 {
+		return filepath.Dir(matches[0]), nil
+	}
+
+	return "", fmt.Errorf("%w: %w", ErrNoLocalPostgres, errNoPostgresBin)
+}
+
+// postgresVersion returns the major version in a path like '/usr/lib/postgresql/17/bin/initdb'.
+func postgresVersion(initdb string) int {
+	version, _ := strconv.Atoi(filepath.Base(filepath.Dir(filepath.Dir(initdb))))
+
+	return version
+}
+
+// localEnv returns the environment of the local postgres processes, with the variables of the option added.
+func localEnv(env map[string]string) []string {
+	result := os.Environ()
+	for key, value := range env {
+		result = append(result, key+"="+value)
+	}
+
+	return result
+}
+
+// freePort returns a port of the loopback interface that is free at the moment.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,158 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// queryRecorder records the statements that gorm runs while it is recording.
+type queryRecorder struct {
+	mu         sync.Mutex
+	installed  bool
+	recording  bool
+	statements []string
+}
+
+// install registers the callbacks that record the statements of gorm.
+func (r *queryRecorder) install(db *gorm.DB) error {
+	callbacks := db.Callback()
+	err := errors.Join(
+		callbacks.Create().After("gorm:create").Register("echoprobe:record", r.record),
+		callbacks.Query().After("gorm:query").Register("echoprobe:record", r.record),
+		callbacks.Update().After("gorm:update").Register("echoprobe:record", r.record),
+		callbacks.Delete().After("gorm:delete").Register("echoprobe:record", r.record),
+		callbacks.Row().After("gorm:row").Register("echoprobe:record", r.record),
+		callbacks.Raw().After("gorm:raw").Register("echoprobe:record", r.record),
+	)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.installed = true
+
+	return nil
+}
+
+// record records the statement of gorm, with its placeholders instead of the values and on a single line.
+func (r *queryRecorder) record(db *gorm.DB) {
+	statement := strings.Join(strings.Fields(db.Statement.SQL.String()), " ")
+	if statement == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.recording {
+		r.statements = append(r.statements, statement)
+	}
+}
+
+// start starts recording, forgetting the statements recorded before.
+func (r *queryRecorder) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.recording = true
+	r.statements = nil
+}
+
+// stop stops recording and returns the statements that were recorded, and whether gorm was recorded at all.
+func (r *queryRecorder) stop() ([]string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.recording = false
+
+	return r.statements, r.installed
+}
+
+// expectsQueries reports whether the case makes any assertion on the statements that the handler runs.
+func expectsQueries(t *Data) bool {
+	return t.ExpectMaxQueries > 0 || len(t.ExpectQueries) > 0 || len(t.ForbidQueries) > 0 ||
+		strings.TrimSpace(t.ExpectQueryLog) != ""
+}
+
+// formatQueries formats the statements as a query log, one statement per line.
+func formatQueries(statements []string) string {
+	var b strings.Builder
+	for _, statement := range statements {
+		b.WriteString(statement)
+		b.WriteString(";\n")
+	}
+
+	return b.String()
+}
+
+// assertQueries asserts the statements that the handler of the case ran, reporting the failures to the given test.
+func assertQueries(it *IntegrationTest, tb testing.TB, t *Data, statements []string, recorded bool) {
+	if !expectsQueries(t) {
+		return
+	}
+
+	require.True(tb, recorded, "the statements are only recorded through gorm, which SkipGorm disables")
+
+	if t.ExpectMaxQueries > 0 {
+		assert.LessOrEqual(tb, len(statements), t.ExpectMaxQueries, "too many statements")
+	}
+
+	for _, expected := range t.ExpectQueries {
+		assert.True(tb, containsQuery(statements, expected), "no statement contains '%s'", expected)
+	}
+
+	for _, forbidden := range t.ForbidQueries {
+		assert.False(tb, containsQuery(statements, forbidden), "a statement contains '%s'", forbidden)
+	}
+
+	if strings.TrimSpace(t.ExpectQueryLog) == "" {
+		return
+	}
+
+	// In update mode, the actual statements become the expected ones.
+	if updateFixtures() {
+		err := it.Fixtures.WriteFixture(t.ExpectQueryLog+".sql", "queries", []byte(formatQueries(statements)))
+		require.NoError(tb, err, "could not update fixture")
+
+		tb.Logf("updated fixture '%s'", t.ExpectQueryLog)
+		return
+	}
+
+	expected, err := it.Fixtures.ReadFixtureE(t.ExpectQueryLog+".sql", "queries")
+	require.NoError(tb, err)
+
+	assert.Equal(tb, strings.TrimSpace(expected), strings.TrimSpace(formatQueries(statements)), "query log mismatch")
+}
+
+// containsQuery reports whether any of the statements contains the given text.
+func containsQuery(statements []string, text string) bool {
+	for _, statement := range statements {
+		if strings.Contains(statement, text) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -18,6 +18,7 @@
 	"io"
 	"net/http/httptest"
 	"strings"
+	"testing"
 
 	"github.com/labstack/echo/v4"
 )
@@ -31,12 +32,24 @@ type Params struct {
 
 // Request creates a new request and a new test service context to which it passes the required parameters.
 func Request(it *IntegrationTest, method string, params Params) (echo.Context, *httptest.ResponseRecorder) {
+	return request(it, it.tb, method, params)
+}
+
+// request creates a new request like Request, reporting the failures to the given test.
+func request(
+	it *IntegrationTest, tb testing.TB, method string, params Params,
+) (echo.Context, *httptest.ResponseRecorder) {
 	var reader io.Reader
 
 	// If the body is not empty, read the body fixture and create a reader from it.
 	// NOTE: The body expects the filename of the fixture, not the content.
 	if strings.TrimSpace(params.Body) != "" {
-		params.Body = it.Fixtures.ReadRequestBody(params.Body)
+		body, err := it.Fixtures.ReadRequestBodyE(params.Body)
+		if err != nil {
+			tb.Fatal(err.Error())
+		}
+
+		params.Body = body
 		reader = strings.NewReader(params.Body)
 	}
 
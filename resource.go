@@ -0,0 +1,91 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"sync"
+)
+
+// Key identifies a resource of type T that an option keeps on an integration test. Keys are compared by identity,
+// so two keys created with the same name are still different keys.
+//
+// Example:
+//
+//	var RedisClient = echoprobe.NewKey[*redis.Client]("redis client")
+//
+//	func (o IntegrationTestWithRedis) Setup(ctx context.Context, it *echoprobe.IntegrationTest) error {
+//		...
+//		echoprobe.SetResource(it, RedisClient, client)
+//		return nil
+//	}
+//
+//	client, _ := echoprobe.Resource(it, RedisClient)
+type Key[T any] struct {
+	name string
+}
+
+// NewKey creates a new key for a resource of type T. The name is only used for descriptive purposes.
+func NewKey[T any](name string) *Key[T] {
+	return &Key[T]{
+		name: name,
+	}
+}
+
+// String returns the name of the key.
+func (k *Key[T]) String() string {
+	return k.name
+}
+
+// SetResource keeps the resource on the integration test under the given key, replacing any previous one.
+func SetResource[T any](it *IntegrationTest, key *Key[T], value T) {
+	it.resources.set(key, value)
+}
+
+// Resource returns the resource kept on the integration test under the given key and whether there is one.
+func Resource[T any](it *IntegrationTest, key *Key[T]) (T, bool) {
+	value, ok := it.resources.get(key)
+	if !ok {
+		var zero T
+		return zero, false
+	}
+
+	return value.(T), true
+}
+
+// resources holds the resources of the options of an integration test. It is safe to use from parallel subtests.
+type resources struct {
+	mu     sync.Mutex
+	values map[any]any
+}
+
+func (r *resources) set(key, value any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values == nil {
+		r.values = make(map[any]any)
+	}
+
+	r.values[key] = value
+}
+
+func (r *resources) get(key any) (any, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	value, ok := r.values[key]
+
+	return value, ok
+}
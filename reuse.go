@@ -0,0 +1,229 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/testcontainers/testcontainers-go"
+)
+
+const (
+	// reuseEnv is the environment variable that enables the reuse of the containers across test runs.
+	reuseEnv = "ECHOPROBE_REUSE"
+
+	// reuseLabel is the label of the containers that are reused, by which CleanupReusedContainers finds them.
+	reuseLabel = "echoprobe.reuse"
+
+	// sessionLabel is the label by which Ryuk, the garbage collector of testcontainers, finds the containers of a test
+	// run to remove them when it ends.
+	sessionLabel = "org.testcontainers.sessionId"
+
+	// The advisory locks serialize the test processes that share a reused postgres container.
+	templateLockID = 7_372_601
+	cloneLockID    = 7_372_602
+)
+
+// reuseContainers reports whether the containers are reused across test runs.
+func reuseContainers() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(reuseEnv))
+
+	return enabled
+}
+
+// reuseName returns the stable name of a reused container, derived from everything that makes its state.
+func reuseName(kind string, config any) (string, error) {
+	buf, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(buf)
+
+	return fmt.Sprintf("echoprobe-%s-%s", kind, hex.EncodeToString(sum[:])[:12]), nil
+}
+
+// reuseRequest names the container of the request for the reuse mode. The session label is removed from the container,
+// so that Ryuk leaves it running when the test run ends.
+func reuseRequest(req *testcontainers.ContainerRequest, name string) {
+	req.Name = name
+	req.Labels = map[string]string{reuseLabel: "true"}
+	req.ConfigModifier = func(config *container.Config) {
+		delete(config.Labels, sessionLabel)
+	}
+}
+
+// postgresReuseName returns the name of the reused postgres container for the option. Besides the configuration of
+// the container, it depends on the contents of the migrations and the init script, so that a change to any of them
+// leads to a new container.
+func postgresReuseName(fixtures *Fixtures, o IntegrationTestWithPostgres) (string, error) {
+	config := struct {
+		Image              string
+		Env                map[string]string
+		Settings           []string
+		Tmpfs              bool
+		Extensions         []string
+		InitSQLTransaction bool
+		InitSQLScript      string
+		Migrations         map[string][]string
+	}{
+		Image:              o.Image,
+		Env:                o.Env,
+		Settings:           o.Settings,
+		Tmpfs:              o.Tmpfs,
+		Extensions:         o.Extensions,
+		InitSQLTransaction: o.InitSQLTransaction,
+	}
+
+	migrations, err := o.readMigrations(fixtures)
+	if err != nil {
+		return "", err
+	}
+
+	config.Migrations = make(map[string][]string, len(migrations))
+	for _, m := range migrations {
+		config.Migrations[m.Name] = m.statements
+	}
+
+	if strings.TrimSpace(o.InitSQLScript) != "" {
+		script, err := fixtures.readFile("fixtures/" + o.InitSQLScript)
+		if err != nil {
+			return "", err
+		}
+
+		config.InitSQLScript = string(script)
+	}
+
+	return reuseName("postgres", config)
+}
+
+// reusePostgresDB starts the reused postgres container of the option, or reattaches to it when an earlier run left
+// it running. Its template database is initialized only once, the integration tests get a copy of it.
+func reusePostgresDB(
+	ctx context.Context, fixtures *Fixtures, o IntegrationTestWithPostgres, logs *containerLogs,
+	logf func(format string, args ...any),
+) (*PostgresDBContainer, error) {
+	name, err := postgresReuseName(fixtures, o)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := setupPostgresDB(ctx, o, name, logs)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.ensureTemplate(ctx, fixtures, o, logf)
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// ensureTemplate initializes the template database, unless an earlier run did so already. The template is
+// initialized under another name and renamed when it is complete, so that an interrupted run does not leave a
+// half-initialized template behind.
+func (c *PostgresDBContainer) ensureTemplate(
+	ctx context.Context, fixtures *Fixtures, o IntegrationTestWithPostgres, logf func(format string, args ...any),
+) error {
+	return c.withLock(ctx, templateLockID, func(exec execer) error {
+		var exists bool
+		err := exec.QueryRowContext(
+			ctx, "SELECT EXISTS (SELECT 1 FROM pg_database WHERE datname = $1)", dbTemplateName,
+		).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			logf("reusing postgres container '%s'", c.name)
+
+			// The migrations were applied by the run that initialized the template.
+			migrations, err := o.readMigrations(fixtures)
+			for _, m := range migrations {
+				c.Migrations = append(c.Migrations, m.Migration)
+			}
+
+			return err
+		}
+
+		initName := dbTemplateName + "_init"
+
+		err = c.dropDatabase(ctx, initName)
+		if err != nil {
+			return err
+		}
+
+		err = c.createDatabase(ctx, initName, "template0")
+		if err != nil {
+			return err
+		}
+
+		err = c.initDatabase(ctx, fixtures, o, initName, logf)
+		if err != nil {
+			return err
+		}
+
+		return c.execMaintenance(ctx, fmt.Sprintf(
+			"ALTER DATABASE %s RENAME TO %s", quoteTable(initName), quoteTable(dbTemplateName),
+		))
+	})
+}
+
+// CleanupReusedContainers removes the stale containers of the reuse mode, which is enabled by the ECHOPROBE_REUSE
+// environment variable. Every change to the configuration or the fixtures of a container leads to a new one, so the
+// stale containers pile up over time. A container is stale when it is not running, or when it was created more than
+// maxAge ago. The running containers that are younger are left alone, since other test processes may be using them.
+func CleanupReusedContainers(ctx context.Context, maxAge time.Duration) error {
+	cli, err := testcontainers.NewDockerClientWithOpts(ctx)
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	containers, err := cli.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", reuseLabel+"=true")),
+	})
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, c := range containers {
+		if c.State == container.StateRunning && time.Since(time.Unix(c.Created, 0)) <= maxAge {
+			continue
+		}
+
+		err = cli.ContainerRemove(ctx, c.ID, container.RemoveOptions{Force: true, RemoveVolumes: true})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not remove container %s: %w", strings.Join(c.Names, ", "), err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
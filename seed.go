@@ -0,0 +1,339 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// Seed loads the seed fixtures into the postgres database of the integration test. A seed is a file under
+// 'fixtures/seeds', looked up with the '.sql', '.yaml', '.yml', '.json' and '.csv' extensions, in that order, unless
+// its name has one of them. A YAML or JSON seed maps tables to their rows, like the fixtures of ExpectDBState. A CSV
+// seed holds the rows of the table it is named after, with the columns in its header, and an empty field is NULL.
+//
+// The rows of all the seeds are inserted in one transaction, with the tables ordered so that the rows a foreign key
+// refers to are inserted first. The sequences of the tables are then reset to follow the highest value in their
+// column, so that the next generated IDs are the same on every run. The SQL seeds run after the rows, in their order.
+func (it *IntegrationTest) Seed(names ...string) {
+	err := it.seed(it.ctx, names...)
+	if err != nil {
+		it.fail(err)
+	}
+}
+
+// seed loads the seed fixtures like Seed, but returns the errors instead of failing the test.
+func (it *IntegrationTest) seed(ctx context.Context, names ...string) error {
+	type script struct {
+		name, content string
+	}
+
+	rows := make(DBState)
+	var scripts []script
+
+	for _, name := range names {
+		content, ext, err := it.Fixtures.readFixtureExt(name, "seeds", ".sql", ".yaml", ".yml", ".json", ".csv")
+		if err != nil {
+			return err
+		}
+
+		switch ext {
+		case ".sql":
+			scripts = append(scripts, script{name: name, content: content})
+		case ".csv":
+			table := strings.TrimSuffix(path.Base(name), ext)
+			tableRows, err := parseCSVRows(content)
+			if err != nil {
+				return fmt.Errorf("could not parse seed '%s': %w", name, err)
+			}
+
+			rows[table] = append(rows[table], tableRows...)
+		default:
+			state, err := parseDBState(name, content)
+			if err != nil {
+				return err
+			}
+
+			for table, tableRows := range state {
+				rows[table] = append(rows[table], tableRows...)
+			}
+		}
+	}
+
+	if it.Container == nil {
+		return errNoPostgres
+	}
+
+	// The seeds have a connection of their own through lib/pq, which passes the values as text, so that postgres
+	// converts them to the types of the columns, like the ones of a CSV seed.
+	db, err := sql.Open("postgres", it.Container.dsn(it.Container.DBName))
+	if err != nil {
+		return fmt.Errorf("database seed error: %w", err)
+	}
+	defer db.Close()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("database seed error: %w", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	err = insertRows(ctx, tx, rows)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range scripts {
+		for _, statement := range splitStatements(s.content) {
+			_, err = tx.ExecContext(ctx, statement)
+			if err != nil {
+				return fmt.Errorf("seed '%s' failed at statement:\n%s\n%w", s.name, statement, err)
+			}
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("database seed error: %w", err)
+	}
+
+	return nil
+}
+
+// parseCSVRows parses CSV rows, with the columns in the header. An empty field is NULL.
+func parseCSVRows(content string) ([]map[string]any, error) {
+	records, err := csv.NewReader(strings.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	rows := make([]map[string]any, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]any, len(record))
+		for i, value := range record {
+			if value == "" {
+				row[records[0][i]] = nil
+				continue
+			}
+
+			row[records[0][i]] = value
+		}
+
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// insertRows inserts the rows in foreign key order and resets the sequences of the tables.
+func insertRows(ctx context.Context, tx *sql.Tx, rows DBState) error {
+	tables := make([]string, 0, len(rows))
+	for table := range rows {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	tables, err := foreignKeyOrder(ctx, tx, tables)
+	if err != nil {
+		return err
+	}
+
+	for _, table := range tables {
+		for _, row := range rows[table] {
+			err = insertRow(ctx, tx, table, row)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = resetSequences(ctx, tx, table)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertRow inserts a row into the table. Nested values, like the ones of JSON columns, are inserted as JSON.
+func insertRow(ctx context.Context, tx *sql.Tx, table string, row map[string]any) error {
+	columns := make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	quoted := make([]string, len(columns))
+	placeholders := make([]string, len(columns))
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		quoted[i] = pq.QuoteIdentifier(column)
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		values[i] = row[column]
+
+		switch row[column].(type) {
+		case map[string]any, []any:
+			buf, err := json.Marshal(row[column])
+			if err != nil {
+				return fmt.Errorf("could not seed table '%s': %w", table, err)
+			}
+			values[i] = string(buf)
+		}
+	}
+
+	query := fmt.Sprintf("INSERT INTO %s DEFAULT VALUES", quoteTable(table))
+	if len(columns) > 0 {
+		query = fmt.Sprintf(
+			"INSERT INTO %s (%s) VALUES (%s)",
+			quoteTable(table), strings.Join(quoted, ", "), strings.Join(placeholders, ", "),
+		)
+	}
+
+	_, err := tx.ExecContext(ctx, query, values...)
+	if err != nil {
+		return fmt.Errorf("could not seed table '%s' with %v: %w", table, row, err)
+	}
+
+	return nil
+}
+
+// foreignKeyOrder orders the tables so that every table comes after the tables its foreign keys refer to. Tables
+// without a dependency between them keep their order.
+func foreignKeyOrder(ctx context.Context, tx *sql.Tx, tables []string) ([]string, error) {
+	oids := make(map[int64]string, len(tables))
+	for _, table := range tables {
+		var oid int64
+		err := tx.QueryRowContext(ctx, "SELECT $1::text::regclass::oid", quoteTable(table)).Scan(&oid)
+		if err != nil {
+			return nil, fmt.Errorf("could not seed table '%s': %w", table, err)
+		}
+
+		oids[oid] = table
+	}
+
+	rows, err := tx.QueryContext(ctx, "SELECT conrelid::oid, confrelid::oid FROM pg_constraint WHERE contype = 'f'")
+	if err != nil {
+		return nil, fmt.Errorf("could not find the foreign keys: %w", err)
+	}
+	defer rows.Close()
+
+	dependencies := make(map[string]map[string]bool)
+	for rows.Next() {
+		var from, to int64
+		if err = rows.Scan(&from, &to); err != nil {
+			return nil, fmt.Errorf("could not find the foreign keys: %w", err)
+		}
+
+		table, ok := oids[from]
+		referenced, refOK := oids[to]
+		if !ok || !refOK || table == referenced {
+			continue
+		}
+
+		if dependencies[table] == nil {
+			dependencies[table] = make(map[string]bool)
+		}
+		dependencies[table][referenced] = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("could not find the foreign keys: %w", err)
+	}
+
+	ordered := make([]string, 0, len(tables))
+	done := make(map[string]bool, len(tables))
+	for len(ordered) < len(tables) {
+		progress := false
+		for _, table := range tables {
+			if done[table] || !dependenciesDone(dependencies[table], done) {
+				continue
+			}
+
+			ordered = append(ordered, table)
+			done[table] = true
+			progress = true
+		}
+
+		if !progress {
+			return nil, fmt.Errorf("the foreign keys between the seeded tables form a cycle")
+		}
+	}
+
+	return ordered, nil
+}
+
+// dependenciesDone reports whether all the dependencies are done.
+func dependenciesDone(dependencies, done map[string]bool) bool {
+	for dependency := range dependencies {
+		if !done[dependency] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// resetSequences sets the sequences of the columns of the table to follow the highest value in their column.
+func resetSequences(ctx context.Context, tx *sql.Tx, table string) error {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT a.attname, pg_get_serial_sequence($1, a.attname)
+		FROM pg_attribute a
+		WHERE a.attrelid = $1::text::regclass AND a.attnum > 0 AND NOT a.attisdropped
+			AND pg_get_serial_sequence($1, a.attname) IS NOT NULL`,
+		quoteTable(table),
+	)
+	if err != nil {
+		return fmt.Errorf("could not find the sequences of table '%s': %w", table, err)
+	}
+
+	sequences := make(map[string]string)
+	for rows.Next() {
+		var column, sequence string
+		if err = rows.Scan(&column, &sequence); err != nil {
+			_ = rows.Close()
+			return fmt.Errorf("could not find the sequences of table '%s': %w", table, err)
+		}
+
+		sequences[column] = sequence
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return fmt.Errorf("could not find the sequences of table '%s': %w", table, err)
+	}
+
+	for column, sequence := range sequences {
+		_, err = tx.ExecContext(ctx, fmt.Sprintf(
+			"SELECT setval($1::text::regclass, COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+			pq.QuoteIdentifier(column), quoteTable(table),
+		), sequence)
+		if err != nil {
+			return fmt.Errorf("could not reset sequence '%s': %w", sequence, err)
+		}
+	}
+
+	return nil
+}
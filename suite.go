@@ -0,0 +1,349 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package echoprobe
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"testing"
+)
+
+// errSuiteNotRunning is returned when an integration test borrows the containers of a suite outside of Suite.Run.
+var errSuiteNotRunning = errors.New("database setup error: the suite is not running")
+
+// Suite shares test containers between all the integration tests of a package. It is created once in TestMain, which
+// hands over the execution of the tests to Run, so that the containers are started before the first test and
+// terminated after the last one.
+//
+// Every IntegrationTest created from the suite borrows the shared containers. For postgres, it gets a database of its
+// own, cloned from the state the init script left behind, so tests do not see each other's writes. The BigQuery
+// emulator has no such isolation and its data is shared between the tests.
+//
+// Example:
+//
+//	var suite = echoprobe.NewSuite(
+//		echoprobe.IntegrationTestWithPostgres{InitSQLScript: "init-db.sql"},
+//	)
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(suite.Run(m))
+//	}
+//
+//	func TestIntegrationHandler(t *testing.T) {
+//		it := suite.NewIntegrationTest(t)
+//		...
+//	}
+type Suite struct {
+	Container   *PostgresDBContainer
+	BqContainer *BigqueryEmulatorContainer
+
+	postgres *IntegrationTestWithPostgres
+	bigquery *IntegrationTestWithBigQuery
+	fixtures *IntegrationTestWithFixtures
+
+	// unavailable is why the containers were not started, which the integration tests of the suite skip on.
+	unavailable error
+	docker      dockerProbe
+}
+
+// SuiteOption is an interface for the options that can be shared by a Suite.
+type SuiteOption interface {
+	applySuite(*Suite)
+}
+
+// NewSuite creates a suite that shares the containers of the given options.
+// It has to be called from the test file, or the IntegrationTestWithFixtures option has to be passed.
+func NewSuite(opts ...SuiteOption) *Suite {
+	s := &Suite{}
+	if dir, err := testpath(); err == nil {
+		s.fixtures = &IntegrationTestWithFixtures{Dir: dir}
+	}
+
+	for _, o := range opts {
+		o.applySuite(s)
+	}
+
+	return s
+}
+
+// Run starts the shared containers, runs the tests and terminates the containers again. It returns the exit code
+// to be passed to os.Exit. When the Docker daemon cannot be reached, the tests still run and the integration tests
+// of the suite are skipped, unless the ECHOPROBE_REQUIRE_DOCKER environment variable is set.
+func (s *Suite) Run(m *testing.M) int {
+	code := 1
+
+	err := s.setup(context.Background())
+	if err != nil {
+		log.Printf("suite setup error: %v", err)
+	} else {
+		code = m.Run()
+	}
+
+	err = s.tearDown(context.Background())
+	if err != nil {
+		log.Printf("error detected during suite teardown: %v", err)
+		code = 1
+	}
+
+	return code
+}
+
+// NewIntegrationTest prepares an integration test that borrows the containers of the suite. Other options, such as
+// IntegrationTestWithMocks, can be passed in addition.
+func (s *Suite) NewIntegrationTest(t *testing.T, opts ...IntegrationTestOption) *IntegrationTest {
+	t.Helper()
+
+	var shared []IntegrationTestOption
+	if s.fixtures != nil {
+		shared = append(shared, *s.fixtures)
+	}
+	if s.postgres != nil {
+		shared = append(shared, sharedPostgres{suite: s})
+	}
+	if s.bigquery != nil {
+		shared = append(shared, sharedBigQuery{suite: s})
+	}
+
+	return NewIntegrationTest(t, append(shared, opts...)...)
+}
+
+// setup starts the shared containers. When it fails, the last lines of the container logs are logged.
+func (s *Suite) setup(ctx context.Context) (err error) {
+	fixtures := &Fixtures{}
+	if s.fixtures != nil {
+		*fixtures = s.fixtures.fixtures()
+	}
+
+	err = checkDocker(ctx, &s.docker, s.options())
+	if errors.Is(err, ErrDockerUnavailable) && !requireDocker() {
+		log.Printf("the integration tests of the suite are skipped: %v", err)
+		s.unavailable = err
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var postgresLogs, bigqueryLogs *containerLogs
+	defer func() {
+		if err != nil {
+			postgresLogs.dump(log.Printf)
+			bigqueryLogs.dump(log.Printf)
+		}
+	}()
+
+	if s.postgres != nil {
+		postgresLogs = newContainerLogs("postgres")
+		if s.postgres.StreamLogs {
+			postgresLogs.startStreaming(log.Printf)
+		}
+	}
+
+	var backend PostgresBackend
+	if s.postgres != nil {
+		backend, err = s.postgres.backend(ctx, &s.docker)
+		if err != nil {
+			return fmt.Errorf("database setup error: %w", err)
+		}
+	}
+
+	if s.postgres != nil && backend == PostgresBackendDocker && reuseContainers() {
+		container, err := reusePostgresDB(ctx, fixtures, *s.postgres, postgresLogs, log.Printf)
+		if err != nil {
+			return fmt.Errorf("database setup error: %w", err)
+		}
+		s.Container = container
+	} else if s.postgres != nil {
+		container, err := startPostgresDB(ctx, *s.postgres, backend, postgresLogs)
+		if err != nil {
+			return fmt.Errorf("database setup error: %w", err)
+		}
+		s.Container = container
+
+		err = container.createDatabase(ctx, dbTemplateName, "template0")
+		if err != nil {
+			return fmt.Errorf("database setup error: %w", err)
+		}
+
+		err = container.initDatabase(ctx, fixtures, *s.postgres, dbTemplateName, log.Printf)
+		if err != nil {
+			return fmt.Errorf("database setup error: %w", err)
+		}
+	}
+
+	if s.bigquery != nil {
+		bigqueryLogs = newContainerLogs("bigquery")
+		if s.bigquery.StreamLogs {
+			bigqueryLogs.startStreaming(log.Printf)
+		}
+
+		container, err := setupBigqueryEmulator(ctx, fixtures, *s.bigquery, bigqueryLogs)
+		if err != nil {
+			return fmt.Errorf("database setup error: %w", err)
+		}
+		s.BqContainer = container
+	}
+
+	return nil
+}
+
+// tearDown terminates the shared containers.
+func (s *Suite) tearDown(ctx context.Context) error {
+	var errs []error
+	if s.BqContainer != nil {
+		if err := s.BqContainer.Terminate(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("bigquery container termination: %w", err))
+		}
+	}
+
+	if s.Container != nil && !s.Container.reused() {
+		if err := s.Container.terminate(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("postgres container termination: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// options returns the options of the suite that start containers.
+func (s *Suite) options() []IntegrationTestOption {
+	var opts []IntegrationTestOption
+	if s.postgres != nil {
+		opts = append(opts, *s.postgres)
+	}
+	if s.bigquery != nil {
+		opts = append(opts, *s.bigquery)
+	}
+
+	return opts
+}
+
+// createDatabase creates a new database for an integration test from the template database.
+func (s *Suite) createDatabase(ctx context.Context) (string, error) {
+	name := randomDatabaseName()
+
+	return name, s.Container.createDatabase(ctx, name, dbTemplateName)
+}
+
+func (o IntegrationTestWithPostgres) applySuite(s *Suite) {
+	s.postgres = &o
+}
+
+func (o IntegrationTestWithBigQuery) applySuite(s *Suite) {
+	s.bigquery = &o
+}
+
+func (o IntegrationTestWithFixtures) applySuite(s *Suite) {
+	s.fixtures = &o
+}
+
+// sharedPostgres is the option through which an integration test borrows the postgres container of a suite.
+type sharedPostgres struct {
+	suite *Suite
+}
+
+// Setup creates the database of the integration test in the shared container.
+func (o sharedPostgres) Setup(ctx context.Context, it *IntegrationTest) error {
+	if o.suite.unavailable != nil {
+		return o.suite.unavailable
+	}
+
+	if o.suite.Container == nil {
+		return errSuiteNotRunning
+	}
+
+	name, err := o.suite.createDatabase(ctx)
+	if err != nil {
+		return fmt.Errorf("database setup error: %w", err)
+	}
+
+	// The copy shares the container of the suite, but points to the database of the integration test.
+	container := *o.suite.Container
+	container.DBName = name
+	it.Container = &container
+
+	if o.suite.postgres.IsolateCases {
+		it.resetDB = func(ctx context.Context) error {
+			return container.restoreDatabase(ctx, name, dbTemplateName)
+		}
+	}
+
+	err = it.connect(*o.suite.postgres)
+	if err != nil {
+		return err
+	}
+
+	it.setenv(o.suite.postgres.ExportEnv.postgres(&container))
+
+	return nil
+}
+
+// TearDown drops the database of the integration test.
+func (o sharedPostgres) TearDown(ctx context.Context, it *IntegrationTest) error {
+	if it.Container == nil {
+		return nil
+	}
+
+	// The logs of the shared container are those of all the tests, the failed one among them.
+	it.dumpLogs(it.Container.logs)
+	it.db.close()
+
+	err := it.Container.dropDatabase(ctx, it.Container.DBName)
+	if err != nil {
+		return fmt.Errorf("postgres database removal: %w", err)
+	}
+
+	return nil
+}
+
+// sharedBigQuery is the option through which an integration test borrows the BigQuery emulator of a suite.
+type sharedBigQuery struct {
+	suite *Suite
+}
+
+// Setup hands the shared BigQuery emulator to the integration test.
+func (o sharedBigQuery) Setup(_ context.Context, it *IntegrationTest) error {
+	if o.suite.unavailable != nil {
+		return o.suite.unavailable
+	}
+
+	if o.suite.BqContainer == nil {
+		return errSuiteNotRunning
+	}
+
+	it.BqContainer = o.suite.BqContainer
+	it.setenv(o.suite.bigquery.ExportEnv.values(it.BqContainer))
+
+	return nil
+}
+
+// TearDown closes the BigQuery clients of the integration test, and leaves the shared BigQuery emulator running for
+// the other tests.
+func (o sharedBigQuery) TearDown(_ context.Context, it *IntegrationTest) error {
+	if it.BqContainer == nil {
+		return nil
+	}
+
+	it.dumpLogs(it.BqContainer.logs)
+
+	err := it.bq.close()
+	if err != nil {
+		return fmt.Errorf("bigquery client close: %w", err)
+	}
+
+	return nil
+}
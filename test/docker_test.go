@@ -0,0 +1,81 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ingka-group/echoprobe"
+)
+
+// dockerChildEnv marks the test binary that runs a test as a child process. Testcontainers looks the Docker host up
+// once per process, so a dead Docker host can only be tried out in a process of its own.
+const dockerChildEnv = "ECHOPROBE_TEST_DOCKER_CHILD"
+
+func TestIntegrationTest_DockerUnavailable(t *testing.T) {
+	o := echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+		Backend:       echoprobe.PostgresBackendDocker,
+	}
+
+	if os.Getenv(dockerChildEnv) != "" {
+		_, err := echoprobe.NewIntegrationTestE(context.Background(), t, o)
+		require.ErrorIs(t, err, echoprobe.ErrDockerUnavailable)
+
+		// The local binaries cannot run an image or keep the data in memory, so these need Docker by default too.
+		for _, auto := range []echoprobe.IntegrationTestWithPostgres{
+			{InitSQLScript: "init-db.sql", Image: "postgis/postgis:17-3.5"},
+			{InitSQLScript: "init-db.sql", Tmpfs: true},
+		} {
+			_, err = echoprobe.NewIntegrationTestE(context.Background(), t, auto)
+			require.ErrorIs(t, err, echoprobe.ErrDockerUnavailable)
+		}
+
+		echoprobe.NewIntegrationTest(t, o)
+		t.Error("the test was not skipped")
+
+		return
+	}
+
+	run := func(requireDocker string) (string, error) {
+		cmd := exec.Command(os.Args[0], "-test.run=^TestIntegrationTest_DockerUnavailable$", "-test.v")
+		cmd.Env = append(os.Environ(),
+			dockerChildEnv+"=true",
+			"DOCKER_HOST=unix:///nonexistent/docker.sock",
+			"ECHOPROBE_REQUIRE_DOCKER="+requireDocker,
+		)
+
+		output, err := cmd.CombinedOutput()
+
+		return string(output), err
+	}
+
+	output, err := run("")
+	require.NoError(t, err, output)
+	require.Contains(t, output, "--- SKIP: TestIntegrationTest_DockerUnavailable")
+	require.Contains(t, output, "docker is not available")
+	require.Contains(t, output, "set ECHOPROBE_REQUIRE_DOCKER=true to fail instead")
+
+	output, err = run("true")
+	require.Error(t, err, output)
+	require.Contains(t, output, "--- FAIL: TestIntegrationTest_DockerUnavailable")
+	require.Contains(t, output, "docker is not available")
+	require.NotContains(t, output, "the test was not skipped")
+}
@@ -15,8 +15,23 @@
 package test
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
+	"time"
+
+	"cloud.google.com/go/civil"
+	"github.com/h2non/gock"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
 
 	"github.com/ingka-group/echoprobe"
 )
@@ -27,9 +42,6 @@ func TestIntegrationHandler_Ready(t *testing.T) {
 	}
 
 	it := echoprobe.NewIntegrationTest(t)
-	defer func() {
-		it.TearDown()
-	}()
 
 	healthHandler := NewHandler()
 
@@ -51,9 +63,6 @@ func TestIntegrationHandler_Live(t *testing.T) {
 	}
 
 	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{})
-	defer func() {
-		it.TearDown()
-	}()
 
 	healthHandler := NewHandler()
 
@@ -155,3 +164,728 @@ func TestIntegrationHandler_MockWeatherWithoutHttpClientConfigured(t *testing.T)
 
 	echoprobe.AssertAll(it, tests)
 }
+
+func TestMock_DefaultTransportInUse(t *testing.T) {
+	config := &echoprobe.MockConfig{
+		UrlPath: "/forecast/amsterdam",
+	}
+
+	first := echoprobe.NewMock("https://weather.test")
+	first.MockRequest(config)
+	t.Cleanup(first.TearDown)
+
+	// The error is reported to the test that owns the Mock.
+	recorder := &errorRecorder{TB: t}
+	second, err := echoprobe.NewIntegrationTestE(context.Background(), recorder, echoprobe.IntegrationTestWithMocks{
+		BaseURL: "https://weather.test",
+	})
+	require.NoError(t, err)
+
+	second.Mock.MockRequest(config)
+	require.Equal(t, []string{
+		"http.DefaultTransport is intercepted by the mocks of another test, tests that run in parallel have to " +
+			"intercept a client of their own with SetHttpClient or Client",
+	}, recorder.errors)
+
+	// A client of its own is not affected.
+	third := echoprobe.NewMock("https://weather.test")
+	_ = third.Client()
+	require.NotPanics(t, func() { third.MockRequest(config) })
+
+	first.TearDown()
+	second.Mock.MockRequest(config)
+	require.Len(t, recorder.errors, 1)
+	second.Mock.TearDown()
+}
+
+func TestMock_SetJSON(t *testing.T) {
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), t, echoprobe.IntegrationTestWithMocks{
+		BaseURL: "https://weather.test",
+	})
+	require.NoError(t, err)
+
+	// The deprecated SetJSON still fills the gock response with the fixture.
+	response := gock.NewResponse()
+	//lint:ignore SA1019 the deprecated function is tested until it is removed
+	it.Mock.SetJSON(response, &echoprobe.MockConfig{Response: "weather-ok"})
+	require.JSONEq(t, it.Fixtures.ReadFixture("weather-ok.json", "mocks"), string(response.BodyBuffer))
+}
+
+func TestIntegrationHandler_VisitWithIsolatedCases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+		IsolateCases:  true,
+	})
+
+	handler := NewVisitHandler(it.Gorm())
+
+	// Without isolation, the second case would count two visits.
+	tests := []echoprobe.Data{
+		{
+			Name:           "ok: first visit",
+			Method:         http.MethodPost,
+			Handler:        handler.Visit,
+			ExpectCode:     http.StatusCreated,
+			ExpectResponse: "visits-1",
+		},
+		{
+			Name:           "ok: first visit again",
+			Method:         http.MethodPost,
+			Handler:        handler.Visit,
+			ExpectCode:     http.StatusCreated,
+			ExpectResponse: "visits-1",
+		},
+		{
+			Name:           "ok: visit after seeded visits",
+			Method:         http.MethodPost,
+			Seeds:          []string{"visits"},
+			Handler:        handler.Visit,
+			ExpectCode:     http.StatusCreated,
+			ExpectResponse: "visits-3",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestIntegrationTest_ResetDBWithoutIsolation(t *testing.T) {
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), t)
+	require.NoError(t, err)
+
+	require.EqualError(t, it.ResetDBE(context.Background()), "database reset error: IsolateCases is not enabled")
+}
+
+func TestIntegrationHandler_VisitWithDBState(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+	})
+
+	handler := NewVisitHandler(it.Gorm())
+
+	tests := []echoprobe.Data{
+		{
+			Name:          "ok: first visit",
+			Method:        http.MethodPost,
+			Handler:       handler.Visit,
+			ExpectCode:    http.StatusCreated,
+			ExpectDBState: "visits-1",
+		},
+		{
+			Name:            "ok: second visit",
+			Method:          http.MethodPost,
+			Handler:         handler.Visit,
+			ExpectCode:      http.StatusCreated,
+			ExpectDBState:   "visits-2",
+			IgnoreDBColumns: []string{"visits.visited_at"},
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestIntegrationHandler_VisitQueries(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+	})
+
+	handler := NewVisitHandler(it.Gorm())
+
+	tests := []echoprobe.Data{
+		{
+			Name:             "ok: visit",
+			Method:           http.MethodPost,
+			Handler:          handler.Visit,
+			ExpectCode:       http.StatusCreated,
+			ExpectMaxQueries: 2,
+			ExpectQueries:    []string{"INSERT INTO visits"},
+			ForbidQueries:    []string{"DELETE"},
+			ExpectQueryLog:   "visit",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestIntegrationTest_MySQL(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithMySQL{
+		InitSQLScripts: []string{"mysql/init-db.sql", "mysql/visits.sql"},
+	})
+
+	require.NotZero(t, it.MySQLContainer.DBPort)
+	require.Equal(t, "test", it.MySQLContainer.DBName)
+
+	var count int64
+	require.NoError(t, it.Gorm().Table("visits").Count(&count).Error)
+	require.EqualValues(t, 2, count)
+}
+
+func TestIntegrationTest_BigQueryClient(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithBigQuery{
+		DataPath: "fixtures/bigquery/data.yaml",
+	})
+
+	ctx := context.Background()
+
+	rows, err := it.BigQueryClient(ctx).Query("SELECT COUNT(*) AS visits FROM shop.visits").Read(ctx)
+	require.NoError(t, err)
+
+	var row struct {
+		Visits int64 `bigquery:"visits"`
+	}
+	require.NoError(t, rows.Next(&row))
+	require.EqualValues(t, 2, row.Visits)
+
+	// The same clients are returned until the test is torn down.
+	require.Same(t, it.BigQueryClient(ctx), it.BigQueryClient(ctx))
+	require.NotNil(t, it.BigQueryReadClient(ctx))
+}
+
+func TestIntegrationTest_BigQueryDataDir(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithBigQuery{
+		DataPath: "fixtures/bigquery/data.yaml",
+		DataDir:  "fixtures/bigquery/analytics",
+		Project:  "analytics",
+		Flags:    []string{"--log-level=debug"},
+	})
+
+	require.Equal(t, "analytics", it.BqContainer.Project)
+
+	ctx := context.Background()
+
+	// The tables of the analytics project come from separate files of the directory.
+	rows, err := it.BigQueryClient(ctx).Query(
+		"SELECT c.name, COUNT(*) AS orders FROM sales.orders o JOIN sales.customers c ON c.id = o.customer_id " +
+			"GROUP BY c.name ORDER BY c.name",
+	).Read(ctx)
+	require.NoError(t, err)
+
+	var row struct {
+		Name   string `bigquery:"name"`
+		Orders int64  `bigquery:"orders"`
+	}
+	require.NoError(t, rows.Next(&row))
+	require.Equal(t, "Jane", row.Name)
+	require.EqualValues(t, 2, row.Orders)
+
+	// The dates keep the value they are written with when the files are merged.
+	rows, err = it.BigQueryClient(ctx).Query("SELECT ordered_on FROM sales.orders WHERE id = 1").Read(ctx)
+	require.NoError(t, err)
+
+	var order struct {
+		OrderedOn civil.Date `bigquery:"ordered_on"`
+	}
+	require.NoError(t, rows.Next(&order))
+	require.Equal(t, "2024-01-01", order.OrderedOn.String())
+}
+
+func TestIntegrationHandler_MockWeatherInParallel(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	// Both tests mock the same URL with a different outcome, which only works when their mocks are isolated.
+	statusCodes := map[string]int{
+		"ok":    http.StatusOK,
+		"error": http.StatusInternalServerError,
+	}
+
+	for name, statusCode := range statusCodes {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithMocks{
+				BaseURL: "https://weather.test",
+			})
+
+			handler := NewApiHandler(it.Mock.Client())
+
+			expectCode := http.StatusOK
+			expectResponse := "weather-ok"
+			if statusCode != http.StatusOK {
+				expectCode = http.StatusServiceUnavailable
+				expectResponse = ""
+			}
+
+			tests := []echoprobe.Data{
+				{
+					Name:           "weather forecast",
+					Method:         http.MethodGet,
+					Handler:        handler.Weather,
+					ExpectCode:     expectCode,
+					ExpectResponse: expectResponse,
+					Mocks: []echoprobe.MockCall{
+						{
+							Config: &echoprobe.MockConfig{
+								UrlPath:    "/forecast/amsterdam",
+								Response:   "weather-ok",
+								StatusCode: statusCode,
+							},
+						},
+					},
+				},
+			}
+
+			echoprobe.AssertAll(it, tests)
+		})
+	}
+}
+
+func TestIntegrationHandler_UpdateFixtures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	t.Setenv("ECHOPROBE_UPDATE", "1")
+	t.Cleanup(func() {
+		_ = os.Remove("fixtures/responses/live-probe-updated.json")
+	})
+
+	it := echoprobe.NewIntegrationTest(t)
+
+	healthHandler := NewHandler()
+
+	tests := []echoprobe.Data{
+		{
+			Name:           "ok: Live probe creates its fixture",
+			Method:         http.MethodGet,
+			Handler:        healthHandler.Live,
+			ExpectCode:     http.StatusOK,
+			ExpectResponse: "live-probe-updated",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+
+	expected, err := os.ReadFile("fixtures/responses/live-probe-ok.json")
+	require.NoError(t, err)
+
+	actual, err := os.ReadFile("fixtures/responses/live-probe-updated.json")
+	require.NoError(t, err)
+	require.Equal(t, string(expected), string(actual))
+}
+
+func TestIntegrationTest_PostgresConfiguration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		Image:      "postgres:17-alpine",
+		Env:        map[string]string{"TZ": "Europe/Amsterdam"},
+		Settings:   []string{"fsync=off", "synchronous_commit=off"},
+		Tmpfs:      true,
+		Extensions: []string{"pgcrypto"},
+	})
+
+	db := it.Gorm()
+
+	var fsync string
+	require.NoError(t, db.Raw("SHOW fsync").Scan(&fsync).Error)
+	require.Equal(t, "off", fsync)
+
+	var version int
+	require.NoError(t, db.Raw("SELECT current_setting('server_version_num')::int / 10000").Scan(&version).Error)
+	require.Equal(t, 17, version)
+
+	var extensions int64
+	require.NoError(t, db.Raw("SELECT COUNT(*) FROM pg_extension WHERE extname = 'pgcrypto'").Scan(&extensions).Error)
+	require.EqualValues(t, 1, extensions)
+}
+
+func TestIntegrationTest_LocalPostgres(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+		Settings:      []string{"fsync=off"},
+		IsolateCases:  true,
+		Backend:       echoprobe.PostgresBackendLocal,
+	})
+	if errors.Is(err, echoprobe.ErrNoLocalPostgres) {
+		t.Skip(err.Error())
+	}
+	require.NoError(t, err)
+
+	require.Nil(t, it.Container.Container)
+	require.Contains(t, it.DSN(), "@127.0.0.1:")
+
+	handler := NewVisitHandler(it.Gorm())
+
+	tests := []echoprobe.Data{
+		{
+			Name:           "ok: first visit",
+			Method:         http.MethodPost,
+			Handler:        handler.Visit,
+			ExpectCode:     http.StatusCreated,
+			ExpectResponse: "visits-1",
+		},
+		{
+			Name:           "ok: first visit again",
+			Method:         http.MethodPost,
+			Handler:        handler.Visit,
+			ExpectCode:     http.StatusCreated,
+			ExpectResponse: "visits-1",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestIntegrationTest_Migrations(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		MigrationsDir: "migrations",
+		InitSQLScript: "init-db.sql",
+	})
+
+	require.Equal(t, []echoprobe.Migration{
+		{Version: 1, Name: "1_create_users.up.sql"},
+		{Version: 2, Name: "2_add_users_email.sql"},
+		{Version: 10, Name: "10_insert_admin.up.sql"},
+	}, it.Container.Migrations)
+
+	var email string
+	require.NoError(t, it.Gorm().Raw("SELECT email FROM users WHERE name = 'admin; root'").Scan(&email).Error)
+	require.Equal(t, "admin@example.com", email)
+}
+
+func TestIntegrationTest_FailedMigration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	_, err := echoprobe.NewIntegrationTestE(context.Background(), t, echoprobe.IntegrationTestWithPostgres{
+		MigrationsFS: fstest.MapFS{
+			"1_create_users.up.sql": {Data: []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);")},
+			"2_insert_users.up.sql": {Data: []byte("INSERT INTO users (id) VALUES (1);\nINSERT INTO user (id) VALUES (2);")},
+		},
+		MigrationsDir: ".",
+	})
+	if errors.Is(err, echoprobe.ErrDockerUnavailable) {
+		t.Skip(err.Error())
+	}
+
+	require.ErrorContains(t, err, "migration '2_insert_users.up.sql' failed")
+	require.ErrorContains(t, err, "INSERT INTO user (id) VALUES (2)")
+}
+
+func TestIntegrationTest_FailedInitScript(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	_, err := echoprobe.NewIntegrationTestE(context.Background(), t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript:      "init-db-broken.sql",
+		InitSQLTransaction: true,
+	})
+	if errors.Is(err, echoprobe.ErrDockerUnavailable) {
+		t.Skip(err.Error())
+	}
+
+	require.ErrorContains(t, err,
+		`init script 'init-db-broken.sql' failed at line 6: ERROR:  relation "visit" does not exist`,
+	)
+}
+
+func TestIntegrationTest_DatabaseAccessors(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+		SkipGorm:      true,
+		ExportEnv: echoprobe.DBEnv{
+			DSN:  "DATABASE_URL",
+			Port: "DATABASE_PORT",
+		},
+	})
+
+	require.Nil(t, it.Db)
+	require.Contains(t, it.DSN(), "/postgres?")
+
+	require.Equal(t, it.DSN(), os.Getenv("DATABASE_URL"))
+	require.Equal(t, strconv.Itoa(it.Container.DBPort), os.Getenv("DATABASE_PORT"))
+
+	_, err := it.SQLDB().Exec("INSERT INTO visits DEFAULT VALUES")
+	require.NoError(t, err)
+
+	var count int
+	require.NoError(t, it.PgxPool().QueryRow(context.Background(), "SELECT COUNT(*) FROM visits").Scan(&count))
+	require.Equal(t, 1, count)
+}
+
+// errorRecorder is a testing.TB that records the errors instead of failing the test.
+type errorRecorder struct {
+	testing.TB
+
+	errors []string
+}
+
+func (r *errorRecorder) Fatal(args ...any) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func (r *errorRecorder) Errorf(format string, args ...any) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func TestIntegrationTest_AccessorsWithoutContainer(t *testing.T) {
+	recorder := &errorRecorder{TB: t}
+
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), recorder)
+	require.NoError(t, err)
+
+	// Every accessor fails the test and returns nothing, instead of using the container that is not there.
+	require.Empty(t, it.DSN())
+	require.Nil(t, it.Gorm())
+	require.Nil(t, it.SQLDB())
+	require.Nil(t, it.PgxPool())
+	require.Nil(t, it.BigQueryClient(context.Background()))
+	require.Nil(t, it.BigQueryReadClient(context.Background()))
+
+	require.Len(t, recorder.errors, 6)
+	require.Contains(t, recorder.errors[0], "there is no postgres database")
+	require.Contains(t, recorder.errors[4], "there is no BigQuery emulator")
+}
+
+func TestAssert_Seeds(t *testing.T) {
+	recorder := &errorRecorder{TB: t}
+
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), recorder)
+	require.NoError(t, err)
+
+	// The handler already ran when Assert is called, so the seeds cannot be loaded anymore.
+	echoprobe.Assert(it, &echoprobe.Data{
+		Name:       "seeded",
+		Seeds:      []string{"visits"},
+		ExpectCode: http.StatusOK,
+	}, &echoprobe.HandlerResult{
+		Response: httptest.NewRecorder(),
+	})
+
+	require.Equal(t, []string{
+		"the Seeds of 'seeded' are only loaded by AssertAll, call it.Seed before the handler runs instead",
+	}, recorder.errors)
+}
+
+func TestAssert_QueryExpectations(t *testing.T) {
+	recorder := &errorRecorder{TB: t}
+
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), recorder)
+	require.NoError(t, err)
+
+	// The statements are only recorded when AssertAll calls the handler.
+	echoprobe.Assert(it, &echoprobe.Data{
+		Name:             "queries",
+		ExpectMaxQueries: 1,
+		ExpectCode:       http.StatusOK,
+	}, &echoprobe.HandlerResult{
+		Response: httptest.NewRecorder(),
+	})
+
+	require.Equal(t, []string{
+		"the statements of 'queries' are only recorded by AssertAll, its query expectations cannot be asserted",
+	}, recorder.errors)
+}
+
+func TestIntegrationTest_Seed(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+	})
+
+	// The addresses refer to the customers, so the customers are inserted first, regardless of the order of the seeds.
+	it.Seed("addresses", "customers")
+
+	var floor int
+	require.NoError(t, it.Gorm().Raw(
+		"SELECT (a.details->>'floor')::int FROM addresses a JOIN customers c ON c.id = a.customer_id WHERE c.name = 'Jane'",
+	).Scan(&floor).Error)
+	require.Equal(t, 2, floor)
+
+	// The sequence follows the seeded IDs.
+	var id int
+	require.NoError(t, it.Gorm().Raw("INSERT INTO customers (name) VALUES ('John') RETURNING id").Scan(&id).Error)
+	require.Equal(t, 3, id)
+}
+
+func TestIntegrationTest_ReusedContainer(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	// The runs are subtests, which would be skipped one by one, so the test skips itself when there is no Docker.
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+
+	t.Setenv("ECHOPROBE_REUSE", "true")
+
+	// Only the container of this test is removed, since other test processes may be using other reused containers.
+	var reused testcontainers.Container
+	t.Cleanup(func() {
+		require.NoError(t, testcontainers.TerminateContainer(reused))
+	})
+
+	var containerIDs []string
+	for _, name := range []string{"first run", "second run"} {
+		t.Run(name, func(t *testing.T) {
+			it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithPostgres{
+				InitSQLScript: "init-db.sql",
+				// A setting of its own keeps the container apart from the ones of the other tests.
+				Settings: []string{"application_name=echoprobe_reuse_test"},
+			})
+
+			reused = it.Container.Container
+			containerIDs = append(containerIDs, it.Container.GetContainerID())
+
+			// Every run starts from the state after the init script.
+			var count int64
+			require.NoError(t, it.Gorm().Table("visits").Count(&count).Error)
+			require.Zero(t, count)
+
+			require.NoError(t, it.Gorm().Exec("INSERT INTO visits DEFAULT VALUES").Error)
+		})
+	}
+
+	require.Len(t, containerIDs, 2)
+	require.Equal(t, containerIDs[0], containerIDs[1])
+}
+
+// logRecorder is a testing.TB that records what is logged to it, and can be marked as failed without failing the test.
+type logRecorder struct {
+	testing.TB
+
+	mu     sync.Mutex
+	logs   []string
+	failed bool
+}
+
+func (r *logRecorder) Logf(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func (r *logRecorder) Failed() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.failed || r.TB.Failed()
+}
+
+func (r *logRecorder) fail() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.failed = true
+}
+
+// logged reports whether a log holds all the texts.
+func (r *logRecorder) logged(texts ...string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, log := range r.logs {
+		found := true
+		for _, text := range texts {
+			found = found && strings.Contains(log, text)
+		}
+
+		if found {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestIntegrationTest_StreamLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	recorder := &logRecorder{TB: t}
+
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), recorder, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+		StreamLogs:    true,
+	})
+	if errors.Is(err, echoprobe.ErrDockerUnavailable) {
+		t.Skip(err.Error())
+	}
+	require.NoError(t, err)
+
+	require.Error(t, it.Gorm().Exec("SELECT * FROM missing_table").Error)
+
+	// The failing statement shows up in the postgres logs as it comes.
+	require.Eventually(t, func() bool {
+		return recorder.logged("[postgres]", "missing_table")
+	}, 10*time.Second, 100*time.Millisecond)
+}
+
+func TestIntegrationTest_DumpLogs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	recorder := &logRecorder{TB: t}
+
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), recorder, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+	})
+	if errors.Is(err, echoprobe.ErrDockerUnavailable) {
+		t.Skip(err.Error())
+	}
+	require.NoError(t, err)
+
+	// The logs of a test that passes are kept to itself.
+	require.NoError(t, it.TearDownE(context.Background()))
+	require.False(t, recorder.logged("container logs"))
+
+	recorder = &logRecorder{TB: t}
+
+	it, err = echoprobe.NewIntegrationTestE(context.Background(), recorder, echoprobe.IntegrationTestWithPostgres{
+		InitSQLScript: "init-db.sql",
+	})
+	require.NoError(t, err)
+
+	// The tail of the logs is dumped when the test failed.
+	recorder.fail()
+	require.NoError(t, it.TearDownE(context.Background()))
+	require.True(t, recorder.logged(
+		"lines of the postgres container logs:", "database system is ready to accept connections",
+	))
+}
@@ -0,0 +1,6 @@
+CREATE TABLE visits (
+    id         SERIAL PRIMARY KEY,
+    visited_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO visit (visited_at) VALUES (NOW());
@@ -0,0 +1,16 @@
+CREATE TABLE visits (
+    id         SERIAL PRIMARY KEY,
+    visited_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE customers (
+    id   SERIAL PRIMARY KEY,
+    name TEXT NOT NULL
+);
+
+CREATE TABLE addresses (
+    id          SERIAL PRIMARY KEY,
+    customer_id INTEGER NOT NULL REFERENCES customers (id),
+    city        TEXT NOT NULL,
+    details     JSONB
+);
@@ -0,0 +1,2 @@
+-- the version is ordered numerically, so this runs after 2_add_users_email.sql
+INSERT INTO users (name, email) VALUES ('admin; root', 'Admin@Example.com');
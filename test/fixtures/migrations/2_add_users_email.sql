@@ -0,0 +1,19 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN email TEXT;
+
+-- +goose StatementBegin
+CREATE FUNCTION normalize_email() RETURNS trigger AS $$
+BEGIN
+    NEW.email := lower(NEW.email);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER users_normalize_email BEFORE INSERT OR UPDATE ON users
+    FOR EACH ROW EXECUTE FUNCTION normalize_email();
+
+-- +goose Down
+DROP TRIGGER users_normalize_email ON users;
+DROP FUNCTION normalize_email();
+ALTER TABLE users DROP COLUMN email;
@@ -0,0 +1,4 @@
+CREATE TABLE visits (
+    id         INT AUTO_INCREMENT PRIMARY KEY,
+    visited_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
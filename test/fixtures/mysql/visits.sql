@@ -0,0 +1,2 @@
+INSERT INTO visits () VALUES ();
+INSERT INTO visits () VALUES ();
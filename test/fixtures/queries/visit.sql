@@ -0,0 +1,2 @@
+INSERT INTO visits DEFAULT VALUES;
+SELECT count(*) FROM "visits";
@@ -0,0 +1,2 @@
+INSERT INTO visits DEFAULT VALUES;
+INSERT INTO visits DEFAULT VALUES;
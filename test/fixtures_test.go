@@ -0,0 +1,106 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ingka-group/echoprobe"
+)
+
+//go:embed fixtures
+var fixtures embed.FS
+
+func TestIntegrationHandler_LiveWithEmbeddedFixtures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithFixtures{
+		FS: fixtures,
+	})
+
+	healthHandler := NewHandler()
+
+	tests := []echoprobe.Data{
+		{
+			Name:           "ok: Live probe",
+			Method:         http.MethodGet,
+			Handler:        healthHandler.Live,
+			ExpectCode:     http.StatusOK,
+			ExpectResponse: "live-probe-ok",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestIntegrationHandler_LiveWithSharedFixtures(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	// The fixtures of another package.
+	it := echoprobe.NewIntegrationTest(t, echoprobe.IntegrationTestWithFixtures{
+		Dir: "suite",
+	})
+
+	healthHandler := NewHandler()
+
+	tests := []echoprobe.Data{
+		{
+			Name:           "ok: Live probe",
+			Method:         http.MethodGet,
+			Handler:        healthHandler.Live,
+			ExpectCode:     http.StatusOK,
+			ExpectResponse: "live-probe-ok",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestFixtures_MissingFixture(t *testing.T) {
+	it := echoprobe.NewIntegrationTest(t)
+
+	_, err := it.Fixtures.ReadResponseE("live-probe-okk")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, filepath.Join("test", "fixtures", "responses"))
+	require.ErrorContains(t, err, "did you mean 'live-probe-ok.json'?")
+
+	_, err = it.Fixtures.ReadResponseE("something-else-entirely")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.NotContains(t, err.Error(), "did you mean")
+}
+
+func TestFixtures_OutsideTheDirectory(t *testing.T) {
+	it := echoprobe.NewIntegrationTest(t)
+
+	// The fixtures of another package, relative to the 'fixtures' directory of this one.
+	content, err := it.Fixtures.ReadFixtureE("live-probe-ok.json", "../../test/suite/fixtures/responses")
+	require.NoError(t, err)
+	require.Equal(t, it.Fixtures.ReadResponse("live-probe-ok"), content)
+
+	_, err = it.Fixtures.ReadFixtureE("live-probe-okk.json", "../suite/fixtures/responses")
+	require.ErrorIs(t, err, fs.ErrNotExist)
+	require.ErrorContains(t, err, filepath.Join("test", "suite", "fixtures", "responses"))
+	require.ErrorContains(t, err, "did you mean 'live-probe-ok.json'?")
+}
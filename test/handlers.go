@@ -22,6 +22,7 @@
 	"time"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 // ServiceHealth defines the health of the service.
@@ -164,3 +165,45 @@ func (h *ApiHandler) Weather(ctx echo.Context) error {
 
 	return ctx.JSON(http.StatusOK, weatherData)
 }
+
+// VisitCount holds the number of recorded visits.
+type VisitCount struct {
+	Visits int64 `json:"visits"`
+} // @name VisitCount
+
+// VisitHandler defines the http router implementation for visit endpoints.
+type VisitHandler struct {
+	db *gorm.DB
+}
+
+// NewVisitHandler creates a new VisitHandler for visit endpoints.
+func NewVisitHandler(db *gorm.DB) *VisitHandler {
+	return &VisitHandler{
+		db: db,
+	}
+}
+
+// Visit records a visit and returns the number of visits so far.
+//
+// @Summary Record visit
+// @Description Records a visit and returns the number of visits so far
+// @Tags visits
+// @ID visits-create
+// @Produce json
+// @Success 201 {object} VisitCount "Created"
+// @Failure 500 "Internal Server Error"
+// @Router /visits [post]
+func (h *VisitHandler) Visit(ctx echo.Context) error {
+	err := h.db.Exec("INSERT INTO visits DEFAULT VALUES").Error
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	var count VisitCount
+	err = h.db.Table("visits").Count(&count.Visits).Error
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return ctx.JSON(http.StatusCreated, count)
+}
@@ -0,0 +1,104 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ingka-group/echoprobe"
+)
+
+// greeting is the resource kept on the integration test by the custom option.
+var greeting = echoprobe.NewKey[*Greeting]("greeting")
+
+// Greeting is a resource of a custom option.
+type Greeting struct {
+	Message  string
+	TornDown bool
+}
+
+// integrationTestWithGreeting is a custom option, implemented outside the echoprobe package.
+type integrationTestWithGreeting struct {
+	Message string
+}
+
+func (o integrationTestWithGreeting) Setup(_ context.Context, it *echoprobe.IntegrationTest) error {
+	echoprobe.SetResource(it, greeting, &Greeting{Message: o.Message})
+	return nil
+}
+
+func (o integrationTestWithGreeting) TearDown(_ context.Context, it *echoprobe.IntegrationTest) error {
+	g, ok := echoprobe.Resource(it, greeting)
+	if ok {
+		g.TornDown = true
+	}
+
+	return nil
+}
+
+func TestIntegrationTest_CustomOption(t *testing.T) {
+	it := echoprobe.NewIntegrationTest(t, integrationTestWithGreeting{Message: "hello"})
+
+	g, ok := echoprobe.Resource(it, greeting)
+	require.True(t, ok)
+	require.Equal(t, "hello", g.Message)
+
+	_, ok = echoprobe.Resource(it, echoprobe.NewKey[*Greeting]("greeting"))
+	require.False(t, ok, "keys with the same name are different keys")
+
+	it.TearDown()
+	require.True(t, g.TornDown)
+}
+
+// integrationTestWithFailure is a custom option whose setup fails.
+type integrationTestWithFailure struct {
+	tornDown *bool
+}
+
+func (o integrationTestWithFailure) Setup(context.Context, *echoprobe.IntegrationTest) error {
+	return errors.New("connection refused")
+}
+
+func (o integrationTestWithFailure) TearDown(context.Context, *echoprobe.IntegrationTest) error {
+	*o.tornDown = true
+	return nil
+}
+
+func TestIntegrationTestE_FailedSetup(t *testing.T) {
+	var tornDown bool
+
+	it, err := echoprobe.NewIntegrationTestE(
+		context.Background(),
+		nil,
+		integrationTestWithGreeting{Message: "hello"},
+		integrationTestWithFailure{tornDown: &tornDown},
+	)
+	require.Nil(t, it)
+	require.ErrorContains(t, err, "integrationTestWithFailure setup: connection refused")
+	require.True(t, tornDown, "the options are torn down after a failed setup")
+}
+
+func TestIntegrationTestE_WithoutTest(t *testing.T) {
+	it, err := echoprobe.NewIntegrationTestE(context.Background(), nil, integrationTestWithGreeting{Message: "hello"})
+	require.NoError(t, err)
+
+	g, _ := echoprobe.Resource(it, greeting)
+	require.NoError(t, it.TearDownE(context.Background()))
+	require.True(t, g.TornDown)
+}
@@ -0,0 +1,77 @@
+// Copyright © 2024 Ingka Holding B.V. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// You may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	  http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package suite
+
+import (
+	"flag"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/ingka-group/echoprobe"
+	"github.com/ingka-group/echoprobe/test"
+)
+
+var suite = echoprobe.NewSuite(echoprobe.IntegrationTestWithPostgres{})
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		os.Exit(m.Run())
+	}
+
+	os.Exit(suite.Run(m))
+}
+
+func TestIntegrationSuite_Live(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	it := suite.NewIntegrationTest(t)
+
+	healthHandler := test.NewHandler()
+
+	tests := []echoprobe.Data{
+		{
+			Name:           "ok: Live probe",
+			Method:         http.MethodGet,
+			Handler:        healthHandler.Live,
+			ExpectCode:     http.StatusOK,
+			ExpectResponse: "live-probe-ok",
+		},
+	}
+
+	echoprobe.AssertAll(it, tests)
+}
+
+func TestIntegrationSuite_IsolatedDatabases(t *testing.T) {
+	if testing.Short() {
+		t.Skip("(skipped)")
+	}
+
+	// Both integration tests create the same table, which only works when each of them has a database of its own.
+	for range 2 {
+		it := suite.NewIntegrationTest(t)
+		require.Same(t, suite.Container.Container, it.Container.Container)
+
+		db := it.Gorm()
+		require.NoError(t, db.Exec("CREATE TABLE items (id SERIAL PRIMARY KEY)").Error)
+
+		it.TearDown()
+	}
+}
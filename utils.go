@@ -18,6 +18,7 @@
 	"errors"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 )
 
@@ -34,3 +35,59 @@ func testpath() (string, error) {
 
 	return "", errors.New("cannot determine filesystem path for current test file")
 }
+
+// closeMatches returns up to three of the candidates that are closest to the name, in order of their distance. It is
+// used to suggest what was meant, when a name cannot be found.
+func closeMatches(name string, candidates []string) []string {
+	type match struct {
+		candidate string
+		distance  int
+	}
+
+	var matches []match
+	for _, candidate := range candidates {
+		distance := levenshtein(strings.ToLower(name), strings.ToLower(candidate))
+		if distance <= max(2, len(name)/3) {
+			matches = append(matches, match{candidate: candidate, distance: distance})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].distance < matches[j].distance
+	})
+
+	var result []string
+	for i := 0; i < len(matches) && i < 3; i++ {
+		result = append(result, matches[i].candidate)
+	}
+
+	return result
+}
+
+// levenshtein returns the number of single character edits needed to change a into b.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	previous := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current := make([]int, len(rb)+1)
+		current[0] = i
+
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+
+		previous = current
+	}
+
+	return previous[len(rb)]
+}